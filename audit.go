@@ -0,0 +1,100 @@
+package sqlitestore
+
+import (
+	"context"
+	"time"
+)
+
+// DeletionReason tells why a session has been deleted, as recorded by the audit log (see SetAuditLog).
+type DeletionReason int
+
+const (
+	// DeletionExpired is the deletion of an expired session by the cleanup.
+	DeletionExpired DeletionReason = iota
+	// DeletionLogout is the explicit deletion of a session, e.g. by Delete when the user logs out.
+	DeletionLogout
+	// DeletionEvicted is the deletion of one of the oldest sessions of an owner having too many of them (see
+	// SetMaxSessionsPerUser).
+	DeletionEvicted
+	// DeletionBulk is the deletion of a session along with many others, e.g. by DeleteAllByUser or DeleteAll.
+	DeletionBulk
+	// DeletionCorrupt is the deletion of a session whose data can't be decoded (see SetDropCorruptSessions).
+	DeletionCorrupt
+)
+
+// String returns the name of the reason, which is how it is stored in the audit log.
+func (r DeletionReason) String() string {
+	switch r {
+	case DeletionExpired:
+		return "expired"
+	case DeletionLogout:
+		return "logout"
+	case DeletionEvicted:
+		return "evicted"
+	case DeletionBulk:
+		return "bulk"
+	case DeletionCorrupt:
+		return "corrupt"
+	default:
+		return "unknown"
+	}
+}
+
+// SetAuditLog sets whether every deleted session is recorded in the audit log, which is the table named after the
+// sessions table with the _audit suffix, e.g. sessions_audit. Each row of the audit log has the ID and the name of the
+// deleted session, the reason of the deletion (see DeletionReason) and when it has been deleted, in the session_id,
+// session_name, reason and deleted_at columns. The rows are written in the same transaction as the deletion, so a
+// session is never deleted without being recorded, and are only removed by PurgeAuditLog.
+// The audit log is created when it is enabled, unless the Schema of the store has SkipTableCreation set, in which case
+// it must already exist. The soft-deleted sessions (see SetSoftDelete) are recorded when they are marked as deleted,
+// while the rows replaced by RenewID aren't recorded, as their sessions live on. Disabled by default.
+func (m *SqliteStore) SetAuditLog(enabled bool) error {
+	if enabled && !m.schema.SkipTableCreation {
+		if _, err := m.db.Exec("CREATE TABLE IF NOT EXISTS " + m.auditTable() + " (id INTEGER PRIMARY KEY, " +
+			"session_id TEXT NOT NULL, session_name TEXT, reason TEXT NOT NULL, deleted_at TIMESTAMP NOT NULL)"); err != nil {
+			return err
+		}
+	}
+	m.auditLog = enabled
+	return nil
+}
+
+// PurgeAuditLog removes from the audit log (see SetAuditLog) the deletions recorded more than olderThan ago and
+// returns the number of rows removed.
+func (m *SqliteStore) PurgeAuditLog(olderThan time.Duration) (int, error) {
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	ctx := context.Background()
+	stmt, err := m.stmt(ctx, "DELETE FROM "+m.auditTable()+" WHERE julianday(deleted_at) < julianday(?)")
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := m.execRetry(ctx, stmt, timestamp(m.now().Add(-olderThan)))
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+// auditTable returns the quoted name of the audit log.
+func (m *SqliteStore) auditTable() string {
+	return "`" + m.schema.unquotedTable() + "_audit`"
+}
+
+// auditDeletion records in the audit log the sessions matching condition, which is a WHERE clause bound to args, as
+// deleted for reason. It must be run in the transaction which deletes them, before deleting them.
+func (m *SqliteStore) auditDeletion(ctx context.Context, reason DeletionReason, condition string, args ...interface{}) error {
+	stmt, err := m.stmt(ctx, "INSERT INTO "+m.auditTable()+" (session_id, session_name, reason, deleted_at) SELECT "+
+		m.schema.IDColumn+", "+m.schema.NameColumn+", ?, ? FROM "+m.table+condition+m.schema.liveCondition())
+	if err != nil {
+		return err
+	}
+	_, err = m.execRetry(ctx, stmt, append([]interface{}{reason.String(), timestamp(m.now())}, args...)...)
+	return err
+}
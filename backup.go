@@ -0,0 +1,77 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrBackupUnsupported is returned by Backup when the DB of the store can't hand out a dedicated connection, i.e. it
+// has no Conn method like the one of *sql.DB.
+var ErrBackupUnsupported = errors.New("Backup unsupported by the database")
+
+// connProvider is the part of *sql.DB which hands out a dedicated connection. It isn't part of DB, which would break
+// the DBs implemented without it.
+type connProvider interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// Backup writes a consistent copy of the whole database of the store to a new file at destPath while the store is
+// live, e.g. for point-in-time backups, so that the service doesn't need to be stopped and the database file doesn't
+// need to be copied. It returns an error if destPath already exists.
+//
+// When the database is opened with github.com/mattn/go-sqlite3, the default, the copy is made with the online backup
+// API of SQLite, in a single step: the copy is the snapshot of the database as of the last committed transaction when
+// the backup starts, the transactions in progress being left out. In WAL mode the pages of the WAL file are copied
+// as well, so the WAL doesn't need to be checkpointed first, and the other connections can keep writing during the
+// backup; with the rollback journal they wait for the backup to finish, see busy_timeout. With the other drivers the
+// copy is made with VACUUM INTO, which requires SQLite 3.27 or newer and is just as consistent.
+//
+// The cleanups of the store wait for the backup to finish, so that they don't delete the expired sessions while the
+// copy is being made. Backup requires db to be a *sql.DB, or to have a Conn method like it, and it can't be run on
+// in-memory databases without shared cache, whose other connections have a database of their own.
+func (m *SqliteStore) Backup(destPath string) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+	provider, ok := m.db.(connProvider)
+	if !ok {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("the backup destination %s already exists", destPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to check the backup destination %s: %w", destPath, err)
+	}
+
+	m.cleanupRunMu.Lock()
+	defer m.cleanupRunMu.Unlock()
+
+	ctx := context.Background()
+	conn, err := provider.Conn(ctx)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer conn.Close()
+
+	var backedUp bool
+	err = conn.Raw(func(driverConn interface{}) error {
+		var err error
+		backedUp, err = backupDriverConn(driverConn, destPath)
+		return err
+	})
+	if err == nil && !backedUp {
+		//not a go-sqlite3 connection, let SQLite make the copy
+		_, err = conn.ExecContext(ctx, "VACUUM INTO ?", destPath)
+	}
+	if err != nil {
+		//don't leave a partial copy behind
+		os.Remove(destPath)
+		m.log().Error("Error backing up the database", "destination", destPath, "error", err)
+		return fmt.Errorf("unable to back up the database to %s: %w", destPath, classifyError(err))
+	}
+	m.log().Info("Backed up the database", "destination", destPath)
+	return nil
+}
@@ -0,0 +1,116 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SessionRecord is a session imported by BulkInsert, e.g. from the store the sessions are migrated from, or a session
+// as it has been written by SaveAndReturn.
+type SessionRecord struct {
+	ID     string
+	Name   string
+	Values map[interface{}]interface{}
+	// CreatedOn is when the session has been created, now if it is zero.
+	CreatedOn time.Time
+	// ExpiresOn is when the session expires, MaxAge after now if it is zero.
+	ExpiresOn time.Time
+}
+
+// BulkInsert stores the records with their own IDs, values and timestamps, e.g. to migrate the sessions of another
+// store without going through Save and the cookies for each one of them. The values are encoded as Save does, and the
+// owner of each session is recorded as well (see SetOwnerKey). The records whose ID is already stored are skipped, or
+// overwritten if overwrite is true.
+//
+// The records are inserted in a single transaction with a single prepared statement, so either all of them are
+// stored or none is; the sessions named after a name mapped to a table of its own (see SetTableForName) are inserted
+// in that table, within the same transaction. No event is emitted and no session is evicted for exceeding the limit
+// set with SetMaxSessionsPerUser. The ID column of the table created by the store is an INTEGER PRIMARY KEY unless
+// Schema.TextIDs is set, so the IDs must be integers unless the table has another type of ID, see SetIDGenerator.
+func (m *SqliteStore) BulkInsert(records []SessionRecord, overwrite bool) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+	for i, record := range records {
+		if record.ID == "" {
+			return fmt.Errorf("the record %d to insert has no ID", i)
+		}
+	}
+
+	return m.atomically(context.Background(), func(ctx context.Context) error {
+		//the statements of the tables the records are inserted in, prepared once for all of them
+		stmts := make(map[*SqliteStore]*sql.Stmt)
+		for _, record := range records {
+			target := m
+			if shard := m.shard(record.Name); shard != nil {
+				target = shard
+			}
+			stmt, ok := stmts[target]
+			if !ok {
+				var err error
+				if stmt, err = target.stmt(ctx, target.bulkInsertQuery(overwrite)); err != nil {
+					return err
+				}
+				stmts[target] = stmt
+			}
+			if err := target.bulkInsert(ctx, stmt, record); err != nil {
+				return fmt.Errorf("unable to insert the session %s: %w", record.ID, err)
+			}
+		}
+		if overwrite {
+			for target := range stmts {
+				target.clearCache(ctx)
+			}
+		}
+		return nil
+	})
+}
+
+// bulkInsertQuery returns the statement which inserts a record, skipping or overwriting the session already stored with
+// the same ID.
+func (m *SqliteStore) bulkInsertQuery(overwrite bool) string {
+	query := "INSERT INTO " + m.table + " (" + m.schema.IDColumn + ", " + m.schema.DataColumn + ", " +
+		m.schema.CreatedOnColumn + ", " + m.schema.ModifiedOnColumn + ", " + m.schema.ExpiresOnColumn + ", " +
+		m.schema.NameColumn + ", " + m.schema.OwnerColumn + ") VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (" + m.schema.IDColumn + ") "
+	if !overwrite {
+		return query + "DO NOTHING"
+	}
+	//the overwritten session is replaced entirely, reviving it if it has been soft-deleted
+	query += "DO UPDATE SET "
+	for _, column := range []string{m.schema.DataColumn, m.schema.CreatedOnColumn, m.schema.ModifiedOnColumn,
+		m.schema.ExpiresOnColumn, m.schema.NameColumn, m.schema.OwnerColumn} {
+		query += column + " = excluded." + column + ", "
+	}
+	return query + m.schema.DeletedAtColumn + " = NULL, " + m.schema.LastAccessColumn + " = NULL, " +
+		m.schema.ClientIPColumn + " = NULL, " + m.schema.UserAgentColumn + " = NULL"
+}
+
+// bulkInsert inserts the record with the statement returned by bulkInsertQuery.
+func (m *SqliteStore) bulkInsert(ctx context.Context, stmt *sql.Stmt, record SessionRecord) error {
+	session := m.newStoredSession(record.ID, record.Name)
+	if record.Values != nil {
+		session.Values = record.Values
+	}
+	encoded, err := m.encode(session)
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	createdOn := record.CreatedOn
+	if createdOn.IsZero() {
+		createdOn = now
+	}
+	expiresOn := record.ExpiresOn
+	if expiresOn.IsZero() {
+		expiresOn = now.Add(time.Second * time.Duration(m.Options.MaxAge))
+	}
+	createdOn, expiresOn = createdOn.In(m.location()), expiresOn.In(m.location())
+
+	//the import counts as a write, e.g. for the idle timeout
+	_, err = m.execRetry(ctx, stmt, record.ID, m.dataArg(encoded), timestamp(createdOn), timestamp(now),
+		timestamp(expiresOn), record.Name, m.ownerOf(session))
+	return err
+}
@@ -0,0 +1,40 @@
+package sqlitestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBulkInsertSkipsOrOverwritesTheStoredSessions(t *testing.T) {
+	store, _ := newTestStore(t)
+	expiresOn := testEpoch.Add(48 * time.Hour)
+	records := []SessionRecord{{ID: "1", Name: "session", Values: map[interface{}]interface{}{"user": "alice"}, ExpiresOn: expiresOn}}
+	if err := store.BulkInsert(records, false); err != nil {
+		t.Fatalf("unable to insert the sessions: %v", err)
+	}
+
+	records[0].Values = map[interface{}]interface{}{"user": "bob"}
+	if err := store.BulkInsert(records, false); err != nil {
+		t.Fatalf("unable to insert the sessions again: %v", err)
+	}
+	session, err := store.GetByID("session", "1")
+	if err != nil {
+		t.Fatalf("unable to load the inserted session: %v", err)
+	}
+	if session.Values["user"] != "alice" {
+		t.Errorf("the stored session has user %v, want it left as it was", session.Values["user"])
+	}
+	if got, _ := session.Values["expires_on"].(time.Time); !got.Equal(expiresOn) {
+		t.Errorf("the inserted session expires on %v, want %v", got, expiresOn)
+	}
+
+	if err = store.BulkInsert(records, true); err != nil {
+		t.Fatalf("unable to overwrite the sessions: %v", err)
+	}
+	if session, err = store.GetByID("session", "1"); err != nil {
+		t.Fatalf("unable to load the overwritten session: %v", err)
+	}
+	if session.Values["user"] != "bob" {
+		t.Errorf("the overwritten session has user %v, want bob", session.Values["user"])
+	}
+}
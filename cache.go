@@ -0,0 +1,189 @@
+package sqlitestore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// SetCache sets up an in-memory cache of the stored sessions in front of the database, so that the sessions which are
+// loaded again and again in quick succession are read from memory. The cache holds at most size sessions, evicting the
+// least recently used ones, for at most ttl each, after which they are read from the database again; a ttl <= 0 keeps
+// them until they are evicted. The sessions saved or extended (see SetSlidingExpiration) by the store are written to
+// the cache as well, while the ones deleted or renewed (see RenewID) are removed from it; the bulk deletions, such as
+// the cleanup, empty the whole cache whenever they delete any session.
+// Only the writes of this store are seen by the cache: if other processes or stores write to the same table, the ttl
+// bounds how long a stale session can be read from the cache. The cached sessions are decoded on every load, like
+// the ones read from the database, so the loaded sessions never share their values.
+// A size <= 0 disables the cache, which is the default.
+func (m *SqliteStore) SetCache(size int, ttl time.Duration) {
+	if size <= 0 {
+		m.cache.Store(nil)
+		return
+	}
+	m.cache.Store(&rowCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	})
+}
+
+// rowCache is a LRU cache of the rows of the sessions table, by ID.
+type rowCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List //the least recently used entry is the last one
+	//incremented by every write, so that a row read from the database before a write can't be cached after it
+	gen uint64
+}
+
+// cacheEntry is an element of the LRU list of a rowCache.
+type cacheEntry struct {
+	row      sessionRow
+	cachedAt time.Time
+}
+
+// rowCache returns the cache the operations run with ctx must use, nil if the cache is disabled or ctx runs them in a
+// transaction, as the cache doesn't see the writes of the transactions until they are committed.
+func (m *SqliteStore) rowCache(ctx context.Context) *rowCache {
+	if txFrom(ctx) != nil {
+		return nil
+	}
+	return m.cache.Load()
+}
+
+// get returns the cached row of the session with the given ID, unless it has been cached more than ttl before now.
+func (c *rowCache) get(id string, now time.Time) (sessionRow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[id]
+	if !ok {
+		return sessionRow{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && now.Sub(entry.cachedAt) >= c.ttl {
+		c.remove(element)
+		return sessionRow{}, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.row, true
+}
+
+// generation returns the number of writes seen by the cache, to be passed to put.
+func (c *rowCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// put caches the row read from the database, unless the cache has seen any write since generation returned gen.
+func (c *rowCache) put(row sessionRow, now time.Time, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	c.store(row, now)
+}
+
+// set caches the row which has just been written to the database.
+func (c *rowCache) set(row sessionRow, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.store(row, now)
+}
+
+// update applies fn to the cached row of the session with the given ID which has just been updated in the database,
+// if it is cached. The columns which have not been updated are kept as they are.
+func (c *rowCache) update(id string, now time.Time, fn func(row *sessionRow)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if element, ok := c.entries[id]; ok {
+		entry := element.Value.(*cacheEntry)
+		fn(&entry.row)
+		entry.cachedAt = now
+		c.lru.MoveToFront(element)
+	}
+}
+
+// invalidate removes the session with the given ID from the cache.
+func (c *rowCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+}
+
+// clear removes every session from the cache.
+func (c *rowCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// store caches the row, evicting the least recently used one if the cache is full. c.mu must be held.
+func (c *rowCache) store(row sessionRow, now time.Time) {
+	if element, ok := c.entries[row.id]; ok {
+		element.Value = &cacheEntry{row: row, cachedAt: now}
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[row.id] = c.lru.PushFront(&cacheEntry{row: row, cachedAt: now})
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes the element from the cache. c.mu must be held.
+func (c *rowCache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).row.id)
+}
+
+// invalidateCached removes the session with the given ID from the cache, if any, once the transaction of ctx, if
+// any, has been committed.
+func (m *SqliteStore) invalidateCached(ctx context.Context, id string) {
+	m.afterCommit(ctx, func() {
+		if cache := m.cache.Load(); cache != nil {
+			cache.invalidate(id)
+		}
+	})
+}
+
+// clearCache empties the cache, if any, once the transaction of ctx, if any, has been committed.
+func (m *SqliteStore) clearCache(ctx context.Context) {
+	m.afterCommit(ctx, func() {
+		if cache := m.cache.Load(); cache != nil {
+			cache.clear()
+		}
+	})
+}
+
+// cacheInserted caches the row which has just been inserted, once the transaction of ctx, if any, has been committed.
+func (m *SqliteStore) cacheInserted(ctx context.Context, row sessionRow) {
+	m.afterCommit(ctx, func() {
+		if cache := m.cache.Load(); cache != nil {
+			cache.set(row, m.now())
+		}
+	})
+}
+
+// cacheUpdated applies fn to the cached row of the session with the given ID which has just been updated, see
+// rowCache.update, once the transaction of ctx, if any, has been committed.
+func (m *SqliteStore) cacheUpdated(ctx context.Context, id string, fn func(row *sessionRow)) {
+	m.afterCommit(ctx, func() {
+		if cache := m.cache.Load(); cache != nil {
+			cache.update(id, m.now(), fn)
+		}
+	})
+}
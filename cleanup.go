@@ -1,7 +1,12 @@
 package sqlitestore
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/gorilla/sessions"
@@ -9,124 +14,1117 @@ import (
 
 var defaultInterval = time.Minute * 5
 
+// maxCleanupBackoff is the longest wait between the ticks of a background cleanup while the sessions table is missing,
+// unless the interval of the cleanup is longer.
+var maxCleanupBackoff = time.Hour
+
+// defaultDeleteChunkSize is the default maximum number of IDs bound to a single DELETE statement, it is kept well
+// below SQLITE_MAX_VARIABLE_NUMBER, which defaults to 999 on older SQLite versions.
+var defaultDeleteChunkSize = 500
+
+// cleanupPageSize is the maximum number of expired sessions selected, loaded and deleted at a time by a cleanup which
+// deletes them one by one, so that its memory stays bounded however many sessions have expired, e.g. after a downtime.
+var cleanupPageSize = 1000
+
+// ErrCleanupRunning is returned when starting a background cleanup for a session name which already has one running.
+var ErrCleanupRunning = errors.New("Cleanup already running for this session name")
+
 // StartCleanup runs a background goroutine every interval that deletes expired sessions from the database.
 // The design is based on https://github.com/nwmac/sqlitestore
+//
+// The store keeps track of the goroutine until it exits, so StopAllCleanups and Close stop it as well. Only one
+// cleanup can run for each session name: if one is already running ErrCleanupRunning is returned, along with an
+// already closed done channel, ErrStoreClosed being returned in the same way if the store has been closed.
+//
+// While the sessions table is missing, e.g. because it is being created by another process during a deploy, the
+// cleanup reports the missing table once, with an error wrapping ErrTableMissing, and then waits twice as long after
+// each tick, up to an hour or the interval if that is longer, until the table shows up again.
+func (m *SqliteStore) StartCleanup(sessionName string, interval time.Duration) (chan<- struct{}, <-chan struct{}, error) {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	quit := make(chan struct{})
+	done, err := m.startCleanup(context.Background(), sessionName, interval, quit)
+	return quit, done, err
+}
 
-func (m *SqliteStore) StartCleanup(sessionName string, interval time.Duration) (chan<- struct{}, <-chan struct{}) {
+// StartCleanupAll runs a background goroutine every interval that deletes the expired sessions of every name from the
+// database. The callbacks receive each session with the name it has been stored with.
+func (m *SqliteStore) StartCleanupAll(interval time.Duration) (chan<- struct{}, <-chan struct{}, error) {
+	return m.StartCleanup("", interval)
+}
+
+// StartCleanupWithContext runs a background goroutine every interval that deletes expired sessions from the database
+// until ctx is cancelled. The returned channel is closed once the goroutine has exited.
+// A cancellation that happens while a cleanup is in progress abandons the remaining deletes of that cleanup.
+// The errors are the same as the ones of StartCleanup.
+func (m *SqliteStore) StartCleanupWithContext(ctx context.Context, sessionName string, interval time.Duration) (<-chan struct{}, error) {
 	if interval <= 0 {
 		interval = defaultInterval
 	}
 
-	quit, done := make(chan struct{}), make(chan struct{})
-	go m.cleanup(sessionName, interval, quit, done)
-	return quit, done
+	return m.startCleanup(ctx, sessionName, interval, nil)
+}
+
+// StopAllCleanups stops all the running background cleanups and waits for them to exit. Unlike Close, it leaves the
+// store usable, so new cleanups can be started afterwards.
+func (m *SqliteStore) StopAllCleanups() {
+	m.stopCleanups()
+}
+
+// StopCleanupByName stops the background cleanup running for sessionName, the one started by StartCleanupAll for an
+// empty sessionName, and waits for it to exit, reporting whether one was running. Unlike StopCleanup, it doesn't need
+// the channels returned when the cleanup has been started, e.g. to stop a cleanup started by another package.
+func (m *SqliteStore) StopCleanupByName(sessionName string) bool {
+	m.cleanupsMu.Lock()
+	run, running := m.cleanups[sessionName]
+	m.cleanupsMu.Unlock()
+	if !running {
+		return false
+	}
+	run.cancel()
+	<-run.done
+	return true
+}
+
+// IsCleanupRunning reports whether a background cleanup is running for sessionName, the one started by
+// StartCleanupAll for an empty sessionName. It turns false as soon as the goroutine of the cleanup exits, for any
+// reason: it has been stopped, its context has been cancelled or the store has been closed. Along with
+// SetCleanupTickCallback, it tells whether a cleanup is alive; the ticks which panic are recovered, so they don't stop
+// the cleanup.
+func (m *SqliteStore) IsCleanupRunning(sessionName string) bool {
+	m.cleanupsMu.Lock()
+	defer m.cleanupsMu.Unlock()
+	_, running := m.cleanups[sessionName]
+	return running
+}
+
+// cleanupRun is a running background cleanup.
+type cleanupRun struct {
+	cancel context.CancelFunc
+	done   <-chan struct{}
+	//when the next tick is scheduled, guarded by cleanupsMu
+	next time.Time
+	//receives the new interval set with SetCleanupInterval
+	interval chan time.Duration
+}
+
+// startCleanup starts the background cleanup goroutine and keeps track of it until it exits, so that Close can stop
+// it. The returned channel is closed once the goroutine has exited, straight away if the cleanup couldn't be started.
+func (m *SqliteStore) startCleanup(ctx context.Context, sessionName string, interval time.Duration, quit <-chan struct{}) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	wait := m.jitteredInterval(interval)
+	run := &cleanupRun{cancel: cancel, done: done, next: m.now().Add(wait), interval: make(chan time.Duration, 1)}
+
+	m.cleanupsMu.Lock()
+	var err error
+	if m.closed.Load() {
+		err = ErrStoreClosed
+	} else if _, running := m.cleanups[sessionName]; running {
+		err = ErrCleanupRunning
+	}
+	if err != nil {
+		m.cleanupsMu.Unlock()
+		cancel()
+		close(done)
+		return done, err
+	}
+	if m.cleanups == nil {
+		m.cleanups = make(map[string]*cleanupRun)
+	}
+	m.cleanups[sessionName] = run
+	m.cleanupsMu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer func() {
+			m.cleanupsMu.Lock()
+			if m.cleanups[sessionName] == run {
+				delete(m.cleanups, sessionName)
+			}
+			m.cleanupsMu.Unlock()
+			cancel()
+		}()
+		m.cleanup(ctx, run, sessionName, interval, wait, quit)
+	}()
+	return done, nil
+}
+
+// stopCleanups stops all the running background cleanups and waits for them to exit.
+func (m *SqliteStore) stopCleanups() {
+	m.cleanupsMu.Lock()
+	runs := make([]*cleanupRun, 0, len(m.cleanups))
+	for _, run := range m.cleanups {
+		runs = append(runs, run)
+	}
+	m.cleanupsMu.Unlock()
+
+	for _, run := range runs {
+		run.cancel()
+	}
+	for _, run := range runs {
+		<-run.done
+	}
 }
 
-// cleanup deletes expired sessions at set intervals.
-func (m *SqliteStore) cleanup(sessionName string, interval time.Duration, quit <-chan struct{}, done chan<- struct{}) {
-	ticker := time.NewTicker(interval)
+// cleanup deletes expired sessions at set intervals, the first time after firstWait, until either ctx is cancelled or
+// quit is signalled.
+func (m *SqliteStore) cleanup(ctx context.Context, run *cleanupRun, sessionName string, interval time.Duration, firstWait time.Duration, quit <-chan struct{}) {
+	timer := time.NewTimer(firstWait)
+	//how long the cleanup waits between the ticks while the table is missing, 0 while it exists
+	var backoff time.Duration
 
 	defer func() {
-		ticker.Stop()
+		timer.Stop()
 	}()
 
 	for {
 		select {
+		case <-ctx.Done():
+			// Handle the context cancellation.
+			return
 		case <-quit:
 			// Handle the quit signal.
-			done <- struct{}{}
 			return
-		case <-ticker.C:
+		case interval = <-run.interval:
+			// Start waiting for the new interval from now.
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(m.scheduleCleanup(run, interval))
+		case <-timer.C:
+			if m.cleanupPaused.Load() {
+				m.reportCleanupTick(0, nil)
+				timer.Reset(m.scheduleCleanup(run, interval))
+				continue
+			}
 			// Delete expired sessions on each tick.
-			err := m.deleteExpiredSessions(sessionName)
-			if err != nil {
-				log.Println("Unable to delete expired sessions: ", err.Error())
+			result := m.cleanupTick(ctx, sessionName)
+			m.cleanupStatsMu.Lock()
+			m.lastCleanupAt = m.now()
+			m.lastCleanupDeleted = result.Deleted
+			m.lastCleanupWouldDelete = result.WouldDelete
+			m.cleanupStatsMu.Unlock()
+			err := result.Err
+			if err != nil && ctx.Err() != nil {
+				//the context has been cancelled mid-tick, the remaining sessions will be deleted by a later cleanup
+				return
+			}
+			m.reportCleanupTick(result.Deleted, err)
+			if isTableMissing(err) {
+				//the table may not have been created yet, e.g. during a rolling deploy: report it once, then retry
+				//less and less often until it shows up
+				if backoff == 0 {
+					m.log().Warn("The sessions table is missing, backing off the cleanup", "session_name", sessionName, "error", err)
+					m.reportCleanupError(classifyError(err))
+				}
+				backoff = min(max(2*backoff, 2*interval), max(maxCleanupBackoff, interval))
+				timer.Reset(m.scheduleCleanup(run, backoff))
+				continue
+			}
+			if backoff != 0 {
+				m.log().Info("The sessions table is back, resuming the cleanup", "session_name", sessionName)
+				backoff = 0
+			}
+			if _, partial := err.(RowErrors); partial {
+				m.log().Warn("Some expired sessions could not be processed", "session_name", sessionName, "error", err)
+				m.reportCleanupError(err)
+			} else if err != nil {
+				m.log().Error("Unable to delete expired sessions", "session_name", sessionName, "error", err)
+				m.reportCleanupError(err)
+			}
+			timer.Reset(m.scheduleCleanup(run, interval))
+		}
+	}
+}
+
+// SetCleanupInterval changes the interval of all the running background cleanups, without restarting them: the
+// next tick of each one of them happens interval after the call, or after the tick in progress if there is one.
+// It returns an error if interval is not positive.
+func (m *SqliteStore) SetCleanupInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid cleanup interval %v, it must be positive", interval)
+	}
+
+	m.cleanupsMu.Lock()
+	defer m.cleanupsMu.Unlock()
+	for _, run := range m.cleanups {
+		//replace the interval which the cleanup hasn't received yet, if any
+		select {
+		case <-run.interval:
+		default:
+		}
+		run.interval <- interval
+	}
+	return nil
+}
+
+// PauseCleanup makes the background cleanups skip their ticks, without stopping them, until ResumeCleanup is called,
+// e.g. during a bulk import. A tick which is already in progress isn't interrupted. CleanupNow is not affected.
+func (m *SqliteStore) PauseCleanup() {
+	m.cleanupPaused.Store(true)
+}
+
+// ResumeCleanup makes the background cleanups paused with PauseCleanup run again from their next tick.
+func (m *SqliteStore) ResumeCleanup() {
+	m.cleanupPaused.Store(false)
+}
+
+// scheduleCleanup returns how long to wait for the next tick of the run, recording when it is going to happen.
+func (m *SqliteStore) scheduleCleanup(run *cleanupRun, interval time.Duration) time.Duration {
+	wait := m.jitteredInterval(interval)
+	m.cleanupsMu.Lock()
+	run.next = m.now().Add(wait)
+	m.cleanupsMu.Unlock()
+	return wait
+}
+
+// CleanupStats describes the background cleanups of the store.
+type CleanupStats struct {
+	// LastCleanupAt is when the last background cleanup of any session name ran, zero if none has run yet.
+	LastCleanupAt time.Time
+	// LastCleanupDeleted is the number of sessions deleted by the last background cleanup.
+	LastCleanupDeleted int
+	// LastCleanupWouldDelete is the number of expired sessions the last background cleanup would have deleted, if it
+	// has run in dry-run mode (see SetCleanupDryRun).
+	LastCleanupWouldDelete int
+	// NextCleanupAt is when the next background cleanup of any session name is scheduled to run, zero if no
+	// background cleanup is running.
+	NextCleanupAt time.Time
+	// Paused reports whether the background cleanups have been paused with PauseCleanup.
+	Paused bool
+}
+
+// CleanupStats returns when the background cleanups of the store ran and are going to run.
+func (m *SqliteStore) CleanupStats() CleanupStats {
+	var stats CleanupStats
+	m.cleanupStatsMu.Lock()
+	stats.LastCleanupAt = m.lastCleanupAt
+	stats.LastCleanupDeleted = m.lastCleanupDeleted
+	stats.LastCleanupWouldDelete = m.lastCleanupWouldDelete
+	m.cleanupStatsMu.Unlock()
+	stats.Paused = m.cleanupPaused.Load()
+
+	m.cleanupsMu.Lock()
+	for _, run := range m.cleanups {
+		if !run.next.IsZero() && (stats.NextCleanupAt.IsZero() || run.next.Before(stats.NextCleanupAt)) {
+			stats.NextCleanupAt = run.next
+		}
+	}
+	m.cleanupsMu.Unlock()
+	return stats
+}
+
+// jitteredInterval returns interval randomly shortened or lengthened by up to the jitter set with SetCleanupJitter.
+func (m *SqliteStore) jitteredInterval(interval time.Duration) time.Duration {
+	if m.cleanupJitter <= 0 {
+		return interval
+	}
+	jittered := time.Duration(float64(interval) * (1 + m.cleanupJitter*(2*rand.Float64()-1)))
+	if jittered <= 0 {
+		//a jitter of 100% or more may have made it vanish
+		return time.Millisecond
+	}
+	return jittered
+}
+
+// ErrCleanupPanicked is wrapped by the error reported when a tick of the background cleanup panics, and by the errors
+// of the callbacks which panicked while a cleanup was processing the expired sessions, see RowErrors.
+var ErrCleanupPanicked = errors.New("Cleanup panicked")
+
+var (
+	// ErrScan is wrapped by the errors of the rows a cleanup selected but could not read.
+	ErrScan = errors.New("Unable to read the session row")
+	// ErrLoad is wrapped by the errors of the expired sessions a cleanup could not load to pass them to the callbacks.
+	// The sessions are deleted anyway.
+	ErrLoad = errors.New("Unable to load the session")
+)
+
+// RowErrors is returned by a cleanup which could not process some of the rows it selected, while it processed the
+// others, e.g. deleting the expired sessions it could read: each of its errors wraps either ErrScan, ErrLoad or, for
+// the callbacks which panicked, ErrCleanupPanicked, so that the partial failures can be told apart with errors.Is and
+// errors.As. The deleted sessions are counted as usual.
+type RowErrors []error
+
+// Error returns the number of rows which could not be processed and their errors.
+func (e RowErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d session rows could not be processed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of the rows.
+func (e RowErrors) Unwrap() []error {
+	return e
+}
+
+// rowErrorsOrNil returns failures as RowErrors, nil if there are none.
+func rowErrorsOrNil(failures []error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return RowErrors(failures)
+}
+
+// cleanupTick runs a tick of the background cleanup, turning a panic into an error so that the tick is abandoned
+// but the following ones still run.
+func (m *SqliteStore) cleanupTick(ctx context.Context, sessionName string) (result CleanupResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = CleanupResult{SessionName: sessionName, Err: fmt.Errorf("%w: %v", ErrCleanupPanicked, r)}
+		}
+	}()
+	return m.runCleanup(ctx, sessionName)
+}
+
+// reportCleanupError passes err to the cleanup error handler, if it has been set.
+// The handler runs in its own goroutine so that a slow handler can't delay the following cleanups.
+func (m *SqliteStore) reportCleanupError(err error) {
+	m.callbacksMu.RLock()
+	handler := m.cleanupErrorHandler
+	m.callbacksMu.RUnlock()
+	if handler == nil {
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				m.log().Error("Cleanup error handler panicked", "panic", r)
+			}
+		}()
+		handler(err)
+	}()
+}
+
+//calls the callback run for the session with the given ID, or for a batch of sessions if id is empty, recovering from
+//its panic, which is appended to failures, if not nil, as an error wrapping ErrCleanupPanicked: the sessions are
+//deleted anyway, as otherwise a callback which keeps panicking for the same session would stop every following
+//cleanup from deleting anything.
+func (m *SqliteStore) callCleanupCallback(id string, failures *[]error, callback func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.log().Error("Cleanup callback panicked", "session_id", id, "panic", r)
+			if failures != nil {
+				if id == "" {
+					*failures = append(*failures, fmt.Errorf("%w: batch callback: %v", ErrCleanupPanicked, r))
+				} else {
+					*failures = append(*failures, fmt.Errorf("%w: session %s: %v", ErrCleanupPanicked, id, r))
+				}
 			}
 		}
+	}()
+	callback()
+}
+
+// reportCleanupTick passes the outcome of a tick of the background cleanup to the cleanup tick callback, if it has
+// been set. Like the cleanup error handler, the callback runs in its own goroutine.
+func (m *SqliteStore) reportCleanupTick(deleted int, err error) {
+	m.callbacksMu.RLock()
+	callback := m.cleanupTickCallback
+	m.callbacksMu.RUnlock()
+	if callback == nil {
+		return
 	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				m.log().Error("Cleanup tick callback panicked", "panic", r)
+			}
+		}()
+		callback(deleted, err)
+	}()
+}
+
+//position of a cleanup among the expired sessions it selects a page at a time: the key of the last row selected so far,
+//which the following page starts after, and the number of rows of the last page, including the ones which could not
+//be read
+type expiredCursor struct {
+	expiry sql.NullFloat64
+	id     sql.NullString
+	rows   int
 }
 
-//gets the IDs of all the expired sessions, in the meantime it calls the callback for each one of them, if it has been set
-func (m *SqliteStore) getExpiredSessionsIdsAndCallCallbacks(sessionName string) ([]string, error) {
-	//select IDs of all expired sessions
-	expiredSessionsSelectStmt, err := m.db.Prepare("SELECT id FROM " + m.table + " WHERE expires_on < datetime(CURRENT_TIMESTAMP,'localtime')")
+//gets the IDs of at most limit expired sessions, the ones which expired first after the last one selected through
+//cursor, which is then moved to the last row selected, or of all of them if limit <= 0, in the meantime it calls the
+//callback for each one of them, if it has been set.
+//The names and the loaded sessions are returned as well, as getSessionsIdsAndCallCallbacks does, while the errors of
+//the rows which could not be read or loaded are appended to failures.
+//An empty sessionName selects the expired sessions of every name.
+func (m *SqliteStore) getExpiredSessionsIdsAndCallCallbacks(ctx context.Context, sessionName string, limit int, cursor *expiredCursor, failures *[]error) ([]string, []string, []*sessions.Session, error) {
+	if limit <= 0 {
+		limit = -1 //no limit
+	}
+	selectStmt := txStmt(ctx, m.stmtSelectExpired)
+	args := []interface{}{timestamp(m.expiryCutoff())}
+	if _, idle := m.idleCutoff(); idle {
+		//the idle sessions are selected as well, by a query which depends on the idle timeout being set
+		var condition string
+		condition, args = m.expiredOrIdleCondition()
+		var err error
+		selectStmt, err = m.stmt(ctx, "SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+", julianday("+m.schema.ExpiresOnColumn+
+			") FROM "+m.table+condition+" AND (? = '' OR "+m.schema.NameColumn+" = ? OR "+m.schema.NameColumn+" IS NULL)"+
+			m.schema.expiredPageCondition()+m.expiredBatchLimit())
+		if err != nil {
+			if !isTableMissing(err) {
+				m.log().Error("Error preparing select statement", "error", err)
+			}
+			return nil, nil, nil, err
+		}
+	}
+	args = append(args, sessionName, sessionName, cursor.expiry, cursor.expiry, cursor.expiry, cursor.id, cursor.id, limit)
+	ids, names, err := m.scanExpiredSessionsIdsAndNames(ctx, selectStmt, sessionName, cursor, failures, args...)
 	if err != nil {
-		log.Println("Error preparing select statement:", err.Error())
-		return nil, err
+		return nil, nil, nil, err
 	}
-	defer expiredSessionsSelectStmt.Close()
-	expiredSessionsRows, err := expiredSessionsSelectStmt.Query()
+	loaded, err := m.loadAndCallCallbacks(ctx, ids, names, m.batchCallback(), failures)
 	if err != nil {
-		log.Println("Error executing select query:", err.Error())
-		return nil, err
+		return nil, nil, nil, err
 	}
-	defer expiredSessionsRows.Close()
+	return ids, names, loaded, nil
+}
 
-	var expiredSessionsIds []string
-	var expiredSessionId string
-	for {
-		if !expiredSessionsRows.Next() {
-			break
+//gets the IDs and the names of the expired sessions selected by selectStmt, which must select their IDs, names and
+//the julianday of their expiry, as scanSessionsIdsAndNames does, moving cursor to the last row. The rows without an
+//ID are skipped, their errors appended to failures.
+func (m *SqliteStore) scanExpiredSessionsIdsAndNames(ctx context.Context, selectStmt *sql.Stmt, sessionName string, cursor *expiredCursor, failures *[]error, args ...interface{}) ([]string, []string, error) {
+	rows, err := selectStmt.QueryContext(ctx, args...)
+	if err != nil {
+		err = classifyError(err)
+		if !isTableMissing(err) {
+			m.log().Error("Error executing select query", "error", err)
+		}
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	var names []string
+	var id, name sql.NullString
+	var expiry sql.NullFloat64
+	cursor.rows = 0
+	for rows.Next() {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		cursor.rows++
+		if err = rows.Scan(&id, &name, &expiry); err == nil && !id.Valid {
+			err = errors.New("the session row has no ID")
+		}
+		if err != nil {
+			m.log().Error("Error scanning select query result", "error", err)
+			if failures != nil {
+				*failures = append(*failures, fmt.Errorf("%w: %v", ErrScan, err))
+			}
+		}
+		if expiry.Valid {
+			//the following page starts after this row, even if it can't be read, so that it isn't selected again
+			cursor.expiry, cursor.id = expiry, id
 		}
-		err = expiredSessionsRows.Scan(&expiredSessionId)
 		if err != nil {
-			log.Println("Error scanning select query result:", err.Error())
 			continue //go to the next session id
 		}
 
-		//append the session id to the slice, so it can be accessed later
-		expiredSessionsIds = append(expiredSessionsIds, expiredSessionId)
+		ids = append(ids, id.String)
+		if name.Valid && name.String != "" {
+			names = append(names, name.String)
+		} else {
+			names = append(names, sessionName)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		err = classifyError(err)
+		if !isTableMissing(err) {
+			m.log().Error("Error iterating select query result", "error", err)
+		}
+		return nil, nil, err
+	}
+
+	return ids, names, nil
+}
+
+//returns the clause which limits the expired sessions selected by a cleanup to the cleanup batch size, the ones which
+//expired first being selected first, in the same order every time. It must be bound to the batch size.
+func (m *SqliteStore) expiredBatchLimit() string {
+	return " ORDER BY julianday(" + m.schema.ExpiresOnColumn + "), " + m.schema.IDColumn + " LIMIT ?"
+}
+
+//gets the IDs of the sessions selected by query, which must select their IDs and names, in the meantime it calls the
+//pre-delete callback for each one of them, if it has been set. The names and the loaded sessions are returned as
+//well, the sessions which could not be loaded are nil, unless no callback has been set, in which case the sessions
+//aren't loaded at all and no sessions are returned. sessionName is used for the rows which have been stored without
+//a name.
+func (m *SqliteStore) getSessionsIdsAndCallCallbacks(ctx context.Context, sessionName string, query string, args ...interface{}) ([]string, []string, []*sessions.Session, error) {
+	ids, names, err := m.selectSessionsIdsAndNames(ctx, sessionName, query, args...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	loaded, err := m.loadAndCallCallbacks(ctx, ids, names, nil, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ids, names, loaded, nil
+}
+
+//loads the sessions with the given IDs and names which are about to be deleted and calls the pre-delete callback for
+//each one of them, if it has been set, and then batchCallback, if not nil, with all of them, returning the loaded
+//sessions, nil for the ones which could not be loaded. The errors of the sessions which could not be loaded, other
+//than the ones deleted in the meantime, are appended to failures, if not nil.
+//No session is loaded, and nil is returned, if no callback has been set.
+func (m *SqliteStore) loadAndCallCallbacks(ctx context.Context, expiredSessionsIds []string, expiredSessionsNames []string, batchCallback func([]*sessions.Session), failures *[]error) ([]*sessions.Session, error) {
+	preDeleteCallback, postDeleteCallback := m.deleteCallbacks()
+	if preDeleteCallback == nil && postDeleteCallback == nil && batchCallback == nil {
+		//nobody is going to see the sessions, don't waste time loading them
+		return nil, nil
+	}
+
+	expiredSessions := make([]*sessions.Session, len(expiredSessionsIds))
+	for i, id := range expiredSessionsIds {
+		if ctx.Err() != nil {
+			//abandon the current batch, nothing has been deleted yet
+			return nil, ctx.Err()
+		}
 
 		//load the session from the database
-		session := sessions.NewSession(m, sessionName)
-		session.ID = expiredSessionId
-		session.Options = &sessions.Options{
-			Path:     m.Options.Path,
-			MaxAge:   m.Options.MaxAge,
-			HttpOnly: m.Options.HttpOnly,
-			Secure:   m.Options.Secure,
-			Domain:   m.Options.Domain,
-			SameSite: m.Options.SameSite,
-		}
-		err := m.load(session, true) //true flag to ignore the check for expired session
+		session := m.newStoredSession(id, expiredSessionsNames[i])
+		err := m.load(ctx, session, true) //true flag to ignore the check for expired session
 		if err != nil {
-			log.Println("Error loading (expired) session:", err.Error())
+			m.log().Debug("Error loading session to delete", "session_id", id, "error", err)
+			if failures != nil && !errors.Is(err, ErrSessionNotFound) {
+				*failures = append(*failures, fmt.Errorf("%w %s: %v", ErrLoad, id, err))
+			}
 			continue //go to the next session id
 		}
+		expiredSessions[i] = session
 
 		//call the callback for this session
-		if m.expiredSessionPreDeleteCallback != nil {
-			m.expiredSessionPreDeleteCallback(session)
+		if preDeleteCallback != nil {
+			m.callCleanupCallback(id, failures, func() { preDeleteCallback(session) })
+		}
+	}
+
+	if batchCallback != nil {
+		batch := make([]*sessions.Session, 0, len(expiredSessions))
+		for _, session := range expiredSessions {
+			if session != nil {
+				batch = append(batch, session)
+			}
+		}
+		if len(batch) > 0 {
+			m.callCleanupCallback("", failures, func() { batchCallback(batch) })
 		}
 	}
 
-	return expiredSessionsIds, nil
+	return expiredSessions, nil
 }
 
-// deletes the expired sessions
-func (m *SqliteStore) deleteExpiredSessions(sessionName string) error {
-	expiredSessionsIds, err := m.getExpiredSessionsIdsAndCallCallbacks(sessionName)
+//gets the IDs and the names of the sessions selected by query, which must select their IDs and names.
+//sessionName is returned as the name of the rows which have been stored without a name.
+//The rows are all read before returning, so that the connection is released before the sessions are loaded, which
+//may need it when the pool has a single one.
+func (m *SqliteStore) selectSessionsIdsAndNames(ctx context.Context, sessionName string, query string, args ...interface{}) ([]string, []string, error) {
+	selectStmt, err := m.readStmt(ctx, query)
 	if err != nil {
+		if !isTableMissing(err) {
+			m.log().Error("Error preparing select statement", "error", err)
+		}
+		return nil, nil, err
+	}
+	return m.scanSessionsIdsAndNames(ctx, selectStmt, sessionName, nil, args...)
+}
+
+//like selectSessionsIdsAndNames, but with the statement of a query which has already been prepared. The errors of the
+//rows which could not be read are appended to failures, if not nil.
+func (m *SqliteStore) scanSessionsIdsAndNames(ctx context.Context, selectStmt *sql.Stmt, sessionName string, failures *[]error, args ...interface{}) ([]string, []string, error) {
+	rows, err := selectStmt.QueryContext(ctx, args...)
+	if err != nil {
+		err = classifyError(err)
+		if !isTableMissing(err) {
+			m.log().Error("Error executing select query", "error", err)
+		}
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	var names []string
+	var id string
+	var name sql.NullString
+	for rows.Next() {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err = rows.Scan(&id, &name); err != nil {
+			m.log().Error("Error scanning select query result", "error", err)
+			if failures != nil {
+				*failures = append(*failures, fmt.Errorf("%w: %v", ErrScan, err))
+			}
+			continue //go to the next session id
+		}
+
+		ids = append(ids, id)
+		if name.Valid && name.String != "" {
+			names = append(names, name.String)
+		} else {
+			names = append(names, sessionName)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		err = classifyError(err)
+		if !isTableMissing(err) {
+			m.log().Error("Error iterating select query result", "error", err)
+		}
+		return nil, nil, err
+	}
+
+	return ids, names, nil
+}
+
+//returns a session with the given ID and name and the options of the store, ready to be loaded from the database
+func (m *SqliteStore) newStoredSession(id string, name string) *sessions.Session {
+	session := sessions.NewSession(m, name)
+	session.ID = id
+	session.Options = cloneOptions(m.Options)
+	return session
+}
+
+// deletes the expired sessions, returning the number of rows actually deleted
+func (m *SqliteStore) deleteExpiredSessions(ctx context.Context, sessionName string) (deleted int, err error) {
+	ctx, span := m.startSpan(ctx, "cleanup", sessionName)
+	examined := 0
+	defer func() {
+		span.SetInt("sqlitestore.cleanup.examined", examined)
+		span.SetInt("sqlitestore.cleanup.deleted", deleted)
+		span.End(err)
+	}()
+
+	if !m.observesDeletions() {
+		//nobody needs to see the expired sessions before they are gone, so delete all of them with a single statement
+		condition, args := m.expiredDeleteCondition(sessionName)
+		deleted, err = m.execDelete(ctx, DeletionExpired, condition, args...)
+		examined = deleted
+		return deleted, err
+	}
+
+	//the expired sessions are deleted a page at a time, the ones which expired first being deleted first, so that only
+	//a page of them is held in memory: each page is selected after the last row of the previous one, so that the rows
+	//which haven't been deleted, e.g. because they can't be read, are selected once, and every row of a page has been
+	//read before its sessions are loaded and deleted.
+	//The rows which can't be processed don't stop the cleanup, their errors are returned along with the outcome
+	var failures []error
+	var cursor expiredCursor
+	for {
+		limit := cleanupPageSize
+		if m.cleanupBatchSize > 0 {
+			limit = min(limit, m.cleanupBatchSize-examined)
+		}
+		expiredSessionsIds, expiredSessionsNames, expiredSessions, err := m.getExpiredSessionsIdsAndCallCallbacks(ctx, sessionName, limit, &cursor, &failures)
+		if err != nil {
+			return deleted, errors.Join(err, rowErrorsOrNil(failures))
+		}
+		examined += cursor.rows
+
+		n, err := m.deleteSessionsWithIds(ctx, expiredSessionsIds, expiredSessionsNames, expiredSessions, SessionExpired, DeletionExpired, &failures)
+		deleted += n
+		if err != nil {
+			return deleted, errors.Join(err, rowErrorsOrNil(failures))
+		}
+		if limit <= 0 || cursor.rows < limit || (m.cleanupBatchSize > 0 && examined >= m.cleanupBatchSize) {
+			//either there are no more expired sessions, or they are left to the following cleanups
+			return deleted, rowErrorsOrNil(failures)
+		}
+	}
+}
+
+// expiredDeleteCondition returns the WHERE clause which matches the sessions named sessionName, every session if it is
+// empty, deleted by a cleanup which deletes them with a single statement, along with the arguments it must be bound to.
+func (m *SqliteStore) expiredDeleteCondition(sessionName string) (string, []interface{}) {
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	condition, args := m.expiredOrIdleCondition()
+	condition += nameCond
+	args = append(args, nameArgs...)
+	if m.cleanupBatchSize > 0 {
+		//DELETE supports no LIMIT unless SQLite has been compiled with SQLITE_ENABLE_UPDATE_DELETE_LIMIT
+		condition = " WHERE " + m.schema.IDColumn + " IN (SELECT " + m.schema.IDColumn + " FROM " + m.table +
+			condition + m.expiredBatchLimit() + ")"
+		args = append(args, m.cleanupBatchSize)
+	}
+	return condition, args
+}
+
+// CleanupQuery returns the statement which deletes the expired sessions named sessionName, of every name if it is
+// empty, along with the arguments it must be bound to, exactly as the cleanups of the store delete them when no
+// callback has been set, e.g. to delete them from a scheduler of the application rather than from the background
+// cleanups, which then don't need to be started at all: the store never deletes the expired sessions on its own,
+// only StartCleanup, StartCleanupAll, StartCleanupWithContext and CleanupNow do.
+//
+// The statement honors the schema of the store, the soft deletion (see SetSoftDelete), in which case it marks the
+// sessions as deleted instead, the cleanup batch size, the expiry grace period and the idle timeout. The current time
+// is bound as of the call, so a statement meant to run later, e.g. from the sqlite3 shell by a cron job, must be
+// bound to the time it runs at: the timestamps are compared through julianday, so julianday(?) matches
+// julianday('now') when bound to the current time, less the grace period. Unlike the cleanups, running the statement
+// calls no callback, emits no event, records nothing in the audit log (see SetAuditLog), doesn't empty the cache (see
+// SetCache) and doesn't vacuum the database, and the tables of the names mapped with SetTableForName have their own
+// statements, returned by their stores.
+func (m *SqliteStore) CleanupQuery(sessionName string) (string, []interface{}) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.CleanupQuery(sessionName)
+	}
+	condition, args := m.expiredDeleteCondition(sessionName)
+	return m.deleteStatement(condition, args)
+}
+
+// deletes the sessions with the given IDs, binding at most cleanupDeleteChunkSize IDs to each DELETE statement.
+// Once a chunk has been deleted, the post-delete callback is called for each of its sessions which has been loaded,
+// loaded is either nil or contains the session (or nil) for each ID, and an event of type event is emitted for each of
+// its sessions, named as in names. The panics of the post-delete callback are recovered from, and appended to failures
+// as callCleanupCallback does, so that they don't stop the deletion of the following chunks.
+// The returned count reflects the rows actually deleted, as some of them may have already been deleted by someone else.
+func (m *SqliteStore) deleteSessionsWithIds(ctx context.Context, ids []string, names []string, loaded []*sessions.Session, event SessionEventType, reason DeletionReason, failures *[]error) (int, error) {
+	chunkSize := m.cleanupDeleteChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultDeleteChunkSize
+	}
+
+	deleted := 0
+	for start := 0; start < len(ids); start += chunkSize {
+		chunk := ids[start:min(start+chunkSize, len(ids))]
+		//bind the IDs to a number of placeholders rounded up to a power of two, repeating the last ID, so that the
+		//statements of the chunks get cached for a few sizes only
+		placeholders := 1
+		for placeholders < len(chunk) {
+			placeholders *= 2
+		}
+		args := make([]interface{}, min(placeholders, chunkSize))
+		for i := range args {
+			args[i] = chunk[min(i, len(chunk)-1)]
+		}
+
+		n, err := m.execDelete(ctx, reason, " WHERE "+m.schema.IDColumn+" IN (?"+strings.Repeat(", ?", len(args)-1)+")", args...)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+
+		//the sessions of this chunk are gone, call the post-delete callback for each one of them
+		if _, postDeleteCallback := m.deleteCallbacks(); postDeleteCallback != nil && loaded != nil {
+			for _, session := range loaded[start : start+len(chunk)] {
+				if session != nil {
+					m.callCleanupCallback(session.ID, failures, func() { postDeleteCallback(session) })
+				}
+			}
+		}
+		for i, id := range chunk {
+			m.emit(ctx, event, id, names[start+i])
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteStatement returns the statement which deletes the sessions matching condition, which is a WHERE clause bound to
+// args, or marks them as deleted when soft deletion is enabled, along with the arguments it must be bound to.
+func (m *SqliteStore) deleteStatement(condition string, args []interface{}) (string, []interface{}) {
+	if m.softDelete {
+		return "UPDATE " + m.table + " SET " + m.schema.DeletedAtColumn + " = ?" + condition + m.schema.liveCondition(),
+			append([]interface{}{timestamp(m.now())}, args...)
+	}
+	return "DELETE FROM " + m.table + condition, args
+}
+
+// deletes the sessions matching condition, which is a WHERE clause bound to args, and returns the number of rows
+// actually deleted, recording them in the audit log as deleted for reason. When soft deletion is enabled the sessions
+// are marked as deleted instead.
+func (m *SqliteStore) execDelete(ctx context.Context, reason DeletionReason, condition string, args ...interface{}) (int, error) {
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	query, execArgs := m.deleteStatement(condition, args)
+
+	var affected int64
+	err := m.withAudit(ctx, reason, condition, args, func(ctx context.Context) error {
+		stmt, err := m.stmt(ctx, query)
+		if err != nil {
+			if !isTableMissing(err) {
+				//the missing table is reported by the callers, see cleanup
+				m.log().Error("Error preparing delete statement", "error", err)
+			}
+			return err
+		}
+		res, err := m.execRetry(ctx, stmt, execArgs...)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
 		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		m.clearCache(ctx)
+	}
+	return int(affected), nil
+}
+
+// CleanupNow synchronously deletes the expired sessions, exactly like a single tick of the background cleanup does,
+// and returns the number of rows actually deleted. It is safe to call while the background cleanups are running: the
+// cleanups are run one at a time, so the callbacks aren't called twice for the same session.
+// When some of the expired sessions can't be read or loaded for the callbacks, the others are processed anyway and
+// the error is a RowErrors with the failures of the rows, while deleted counts the sessions deleted.
+func (m *SqliteStore) CleanupNow(sessionName string) (deleted int, err error) {
+	result := m.runCleanup(context.Background(), sessionName)
+	return result.Deleted, result.Err
+}
+
+// CleanupResult describes the outcome of a cleanup of the expired sessions.
+type CleanupResult struct {
+	// SessionName is the name of the cleaned up sessions, empty if the sessions of every name have been cleaned up.
+	SessionName string
+	// Deleted is the number of sessions actually deleted.
+	Deleted int
+	// WouldDelete is the number of expired sessions which would have been deleted, if the cleanup has run in dry-run
+	// mode (see SetCleanupDryRun), in which case Deleted is 0.
+	WouldDelete int
+	// Duration is how long the cleanup took.
+	Duration time.Duration
+	// Err is the error which made the cleanup fail, if any.
+	Err error
+}
+
+// AddCleanupObserver registers a function which gets called with the outcome of every cleanup, both the background
+// ones and the ones run by CleanupNow. Observers are called synchronously at the end of the cleanup, so they must
+// return quickly. It is safe to call while the background cleanup is running.
+func (m *SqliteStore) AddCleanupObserver(observer func(CleanupResult)) {
+	m.cleanupObserversMu.Lock()
+	defer m.cleanupObserversMu.Unlock()
+	m.cleanupObservers = append(m.cleanupObservers, observer)
+}
+
+// deleteExpiredSessionsExclusively deletes the expired sessions and vacuums the database if needed, making sure that
+// no other cleanup is running in the meantime, so that they don't select the same expired sessions.
+func (m *SqliteStore) deleteExpiredSessionsExclusively(ctx context.Context, sessionName string) (int, error) {
+	m.cleanupRunMu.Lock()
+	defer m.cleanupRunMu.Unlock()
+
+	deleted, err := m.deleteExpiredSessions(ctx, sessionName)
+	if _, partial := err.(RowErrors); err == nil || partial {
+		m.vacuumAfterCleanup(ctx, deleted)
+	}
+	return deleted, err
+}
+
+// runCleanup deletes the expired sessions, or only selects them in dry-run mode, and notifies the cleanup observers of
+// the outcome.
+func (m *SqliteStore) runCleanup(ctx context.Context, sessionName string) CleanupResult {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.runCleanup(ctx, sessionName)
+	}
+	result := CleanupResult{SessionName: sessionName}
+	if m.closed.Load() {
+		result.Err = ErrStoreClosed
+		return result
 	}
 
-	for i := 0; i < len(expiredSessionsIds); i++ {
-		//delete the session from the database
-		_, delErr := m.stmtDelete.Exec(expiredSessionsIds[i])
-		if delErr != nil {
-			return delErr
+	start := time.Now()
+	if m.cleanupDryRun.Load() {
+		result.WouldDelete, result.Err = m.selectExpiredSessionsExclusively(ctx, sessionName)
+	} else {
+		result.Deleted, result.Err = m.deleteExpiredSessionsExclusively(ctx, sessionName)
+	}
+	if sessionName == "" {
+		//the sessions of every name are cleaned up, including the ones stored in the tables of their names
+		for _, shard := range m.allShards() {
+			shardResult := shard.runCleanup(ctx, "")
+			result.Deleted += shardResult.Deleted
+			result.WouldDelete += shardResult.WouldDelete
+			result.Err = errors.Join(result.Err, shardResult.Err)
 		}
 	}
+	result.Duration = time.Since(start)
 
-	return nil
+	m.cleanupObserversMu.Lock()
+	observers := m.cleanupObservers
+	m.cleanupObserversMu.Unlock()
+	for _, observer := range observers {
+		observer(result)
+	}
+
+	return result
+}
+
+// SetCleanupDryRun sets whether the cleanups, both the background ones and the ones run by CleanupNow, only select
+// the expired sessions they would delete, without deleting them, e.g. to check the expiry of the sessions against the
+// real data before enabling it. The selected sessions are passed to the pre-delete and batch callbacks, if set, and
+// their IDs are logged; their number is reported by CleanupStats and to the cleanup observers as WouldDelete.
+// Disabled by default.
+func (m *SqliteStore) SetCleanupDryRun(enabled bool) {
+	m.cleanupDryRun.Store(enabled)
+}
+
+// selectExpiredSessionsExclusively is the dry run of deleteExpiredSessionsExclusively: it selects the expired
+// sessions, calling the pre-delete callbacks, and returns their number without deleting them. They are selected a page
+// at a time, like deleteExpiredSessions does, each page after the last row of the previous one as they aren't deleted.
+func (m *SqliteStore) selectExpiredSessionsExclusively(ctx context.Context, sessionName string) (int, error) {
+	m.cleanupRunMu.Lock()
+	defer m.cleanupRunMu.Unlock()
+
+	var failures []error
+	var cursor expiredCursor
+	selected, examined := 0, 0
+	for {
+		limit := cleanupPageSize
+		if m.cleanupBatchSize > 0 {
+			limit = min(limit, m.cleanupBatchSize-examined)
+		}
+		ids, _, _, err := m.getExpiredSessionsIdsAndCallCallbacks(ctx, sessionName, limit, &cursor, &failures)
+		if err != nil {
+			return selected, errors.Join(err, rowErrorsOrNil(failures))
+		}
+		if len(ids) > 0 {
+			m.log().Info("Dry run, the expired sessions have not been deleted", "session_name", sessionName, "count", len(ids), "session_ids", ids)
+		}
+		selected += len(ids)
+		examined += cursor.rows
+		if limit <= 0 || cursor.rows < limit || (m.cleanupBatchSize > 0 && examined >= m.cleanupBatchSize) {
+			return selected, rowErrorsOrNil(failures)
+		}
+	}
 }
 
 // StopCleanup stops the background cleanup from running.
+// It returns straight away if the cleanup has already exited, e.g. because the store has been closed.
 func (m *SqliteStore) StopCleanup(quit chan<- struct{}, done <-chan struct{}) {
-	quit <- struct{}{}
-	<-done
+	select {
+	case quit <- struct{}{}:
+		<-done
+	case <-done:
+	}
+}
+
+// ErrCleanupStopTimeout is returned by StopCleanupWithTimeout when the cleanup doesn't exit in time.
+var ErrCleanupStopTimeout = errors.New("Timed out waiting for the cleanup to stop")
+
+// StopCleanupWithTimeout is like StopCleanup, but it gives up waiting for the cleanup to exit after timeout, returning
+// ErrCleanupStopTimeout, e.g. to bound the time spent shutting down while a slow cleanup is in progress.
+// The cleanup is stopped anyway, it exits once its current tick is over.
+func (m *SqliteStore) StopCleanupWithTimeout(quit chan<- struct{}, done <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case quit <- struct{}{}:
+	case <-done:
+		return nil
+	case <-timer.C:
+		//the cleanup is busy with a tick, signal it as soon as the tick is over
+		go m.StopCleanup(quit, done)
+		return ErrCleanupStopTimeout
+	}
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrCleanupStopTimeout
+	}
 }
 
 func (m *SqliteStore) SetExpiredSessionPreDeleteCallback(callback func(*sessions.Session)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
 	m.expiredSessionPreDeleteCallback = callback
 }
+
+// SetExpiredSessionsBatchCallback sets a callback which gets called by each cleanup with the expired sessions it is
+// about to delete, e.g. to release their remote resources with a single request. The expired sessions are deleted one
+// page of expired sessions at a time, and the callback is called once for each page, so it gets at most as many
+// sessions as the page or the cleanup batch size (see SetCleanupBatchSize), whichever is smaller. The sessions which
+// could not be loaded are left out, and the callback isn't called if there are no expired sessions. When the
+// pre-delete callback is set as well, it is called for each session first, and the batch callback is called afterwards.
+func (m *SqliteStore) SetExpiredSessionsBatchCallback(callback func([]*sessions.Session)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.expiredSessionsBatchCallback = callback
+}
+
+// SetExpiredSessionPostDeleteCallback sets a callback which gets called for each expired session after it has been
+// deleted from the database. It isn't called for the sessions whose deletion failed.
+func (m *SqliteStore) SetExpiredSessionPostDeleteCallback(callback func(*sessions.Session)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.expiredSessionPostDeleteCallback = callback
+}
+
+//returns the batch callback, which may be set concurrently with the cleanup as well
+func (m *SqliteStore) batchCallback() func([]*sessions.Session) {
+	m.callbacksMu.RLock()
+	defer m.callbacksMu.RUnlock()
+	return m.expiredSessionsBatchCallback
+}
+
+//returns the pre-delete and post-delete callbacks, which may be set concurrently with the cleanup
+func (m *SqliteStore) deleteCallbacks() (func(*sessions.Session), func(*sessions.Session)) {
+	m.callbacksMu.RLock()
+	defer m.callbacksMu.RUnlock()
+	return m.expiredSessionPreDeleteCallback, m.expiredSessionPostDeleteCallback
+}
+
+// SetCleanupBatchSize sets the maximum number of expired sessions deleted by each cleanup, the ones which expired first
+// being deleted first, so that a cleanup doesn't keep the database locked for too long when lots of sessions expire
+// at once: the remaining ones are deleted by the following cleanups. When the sessions are deleted one by one, because
+// a callback has been set, it limits the number of sessions loaded by each cleanup as well.
+// It should be called before StartCleanup. By default the number is unlimited, n <= 0 restores the default.
+func (m *SqliteStore) SetCleanupBatchSize(n int) {
+	m.cleanupBatchSize = n
+}
+
+// SetCleanupJitter sets the fraction of the interval by which the time between two background cleanups is randomly
+// shortened or lengthened, e.g. 0.1 makes it vary between 90% and 110% of the interval, so that the cleanups of many
+// instances sharing the database don't all run at the same time. It should be called before StartCleanup.
+// By default there is no jitter, fraction <= 0 disables it.
+func (m *SqliteStore) SetCleanupJitter(fraction float64) {
+	m.cleanupJitter = fraction
+}
+
+// SetCleanupDeleteChunkSize sets the maximum number of session IDs deleted by a single DELETE statement, when the
+// expired sessions have to be deleted one by one (i.e. when a pre-delete callback has been set). ExtendExpiry and
+// ExtendExpiryEvenIfExpired bind at most as many IDs to each of their UPDATE statements as well.
+// It must not exceed the SQLITE_MAX_VARIABLE_NUMBER the SQLite library has been compiled with, a value <= 0 restores
+// the default (500).
+func (m *SqliteStore) SetCleanupDeleteChunkSize(size int) {
+	m.cleanupDeleteChunkSize = size
+}
+
+// SetCleanupErrorHandler sets a handler which gets called with the error of each failed background cleanup, a
+// RowErrors for the cleanups which could only process some of the expired sessions, and of each corrupt session
+// dropped by New (see SetDropCorruptSessions).
+// The handler is called in a new goroutine, so it never blocks the cleanup, but it may be called concurrently
+// if cleanups keep failing faster than it returns. A panic in the handler is recovered and logged,
+// it doesn't stop the cleanup nor crash the program.
+func (m *SqliteStore) SetCleanupErrorHandler(handler func(error)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.cleanupErrorHandler = handler
+}
+
+// SetCleanupTickCallback sets a callback which gets called at the end of every tick of the background cleanups with
+// the number of sessions deleted and the error of the tick, if any, whether or not it deleted any session, e.g. to
+// heartbeat a liveness metric: unlike the pre-delete and post-delete callbacks and the cleanup error handler, it is
+// called on the ticks which delete nothing as well, so a cleanup which has silently stopped is revealed by the absence
+// of calls. The ticks skipped while the cleanups are paused (see PauseCleanup) are reported as deleting nothing, while
+// the tick interrupted by the cancellation of the context of the cleanup isn't reported. In dry-run mode (see
+// SetCleanupDryRun) no session is deleted, so deleted is always 0.
+// Like the cleanup error handler, the callback is called in a new goroutine and a panic in it is recovered and logged.
+func (m *SqliteStore) SetCleanupTickCallback(callback func(deleted int, err error)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.cleanupTickCallback = callback
+}
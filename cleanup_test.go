@@ -0,0 +1,414 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/maxbarbieri/sqlitestore/sqlitestoretest"
+)
+
+func TestCleanupDeletesExpiredSessions(t *testing.T) {
+	store, clock := newTestStore(t)
+	expired := saveSession(t, store, "session", 60, nil)
+	active := saveSession(t, store, "session", 3600, nil)
+
+	clock.Advance(2 * time.Minute)
+	deleted, err := store.CleanupNow("")
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d sessions, want 1", deleted)
+	}
+	if _, err = store.GetByIDEvenIfExpired("session", expired.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("loading the expired session returned %v, want ErrSessionNotFound", err)
+	}
+	if _, err = store.GetByID("session", active.ID); err != nil {
+		t.Errorf("unable to load the active session: %v", err)
+	}
+}
+
+func TestCleanupCallsTheCallbacks(t *testing.T) {
+	store, clock := newTestStore(t)
+	saved := saveSession(t, store, "session", 60, map[interface{}]interface{}{"user": "alice"})
+
+	var preDeleted, postDeleted []string
+	store.SetExpiredSessionPreDeleteCallback(func(session *sessions.Session) {
+		if session.Values["user"] != "alice" {
+			t.Errorf("the pre-delete callback got the values %v", session.Values)
+		}
+		preDeleted = append(preDeleted, session.ID)
+	})
+	store.SetExpiredSessionPostDeleteCallback(func(session *sessions.Session) {
+		postDeleted = append(postDeleted, session.ID)
+	})
+
+	clock.Advance(2 * time.Minute)
+	if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+		t.Fatalf("cleanup deleted %d sessions with error %v, want 1 and no error", deleted, err)
+	}
+	if len(preDeleted) != 1 || preDeleted[0] != saved.ID {
+		t.Errorf("the pre-delete callback got %v, want [%s]", preDeleted, saved.ID)
+	}
+	if len(postDeleted) != 1 || postDeleted[0] != saved.ID {
+		t.Errorf("the post-delete callback got %v, want [%s]", postDeleted, saved.ID)
+	}
+}
+
+func TestCleanupDeletesTheSessionsWhoseCallbackPanics(t *testing.T) {
+	store, clock := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	calls := 0
+	store.SetExpiredSessionPreDeleteCallback(func(*sessions.Session) {
+		calls++
+		if calls == 1 {
+			panic("bad session")
+		}
+	})
+
+	clock.Advance(2 * time.Minute)
+	deleted, err := store.CleanupNow("")
+	if deleted != 3 {
+		t.Errorf("deleted %d sessions, want 3", deleted)
+	}
+	var rowErrs RowErrors
+	if !errors.As(err, &rowErrs) || len(rowErrs) != 1 || !errors.Is(err, ErrCleanupPanicked) {
+		t.Errorf("cleanup returned %v, want a RowErrors with the panic of the callback", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions are left, want none", count)
+	}
+	if deleted, err = store.CleanupNow(""); deleted != 0 || err != nil {
+		t.Errorf("the following cleanup deleted %d sessions with error %v, want none", deleted, err)
+	}
+}
+
+func TestCleanupPagesTheExpiredSessions(t *testing.T) {
+	defer func(pageSize int) { cleanupPageSize = pageSize }(cleanupPageSize)
+	cleanupPageSize = 2
+
+	store, clock := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	var batches []int
+	store.SetExpiredSessionsBatchCallback(func(expired []*sessions.Session) { batches = append(batches, len(expired)) })
+
+	clock.Advance(2 * time.Minute)
+	if deleted, err := store.CleanupNow(""); err != nil || deleted != 5 {
+		t.Fatalf("cleanup deleted %d sessions with error %v, want 5 and no error", deleted, err)
+	}
+	if len(batches) != 3 || batches[0] != 2 || batches[1] != 2 || batches[2] != 1 {
+		t.Errorf("the batch callback got pages of %v sessions, want [2 2 1]", batches)
+	}
+}
+
+func TestCleanupRespectsTheSessionName(t *testing.T) {
+	store, clock := newTestStore(t)
+	saveSession(t, store, "a", 60, nil)
+	other := saveSession(t, store, "b", 60, nil)
+
+	clock.Advance(2 * time.Minute)
+	if deleted, err := store.CleanupNow("a"); err != nil || deleted != 1 {
+		t.Fatalf("cleanup deleted %d sessions with error %v, want 1 and no error", deleted, err)
+	}
+	if _, err := store.GetByIDEvenIfExpired("b", other.ID); err != nil {
+		t.Errorf("the session of the other name has been deleted: %v", err)
+	}
+}
+
+func TestCleanupDryRunDeletesNothing(t *testing.T) {
+	store, clock := newTestStore(t)
+	saveSession(t, store, "session", 60, nil)
+	store.SetCleanupDryRun(true)
+
+	clock.Advance(2 * time.Minute)
+	if _, err := store.CleanupNow(""); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d sessions are left, want 1", count)
+	}
+}
+
+func TestIsCleanupRunning(t *testing.T) {
+	store, _ := newTestStore(t)
+	quit, done, err := store.StartCleanup("session", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsCleanupRunning("session") || store.IsCleanupRunning("") {
+		t.Error("the running cleanup is not reported for its session name only")
+	}
+	store.StopCleanup(quit, done)
+	if store.IsCleanupRunning("session") {
+		t.Error("the stopped cleanup is still reported as running")
+	}
+}
+
+func TestCleanupTickCallbackSetWhileRunning(t *testing.T) {
+	store, _ := newTestStore(t)
+	quit, done, err := store.StartCleanup("", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.StopCleanup(quit, done)
+
+	ticks := make(chan int, 1)
+	store.SetCleanupTickCallback(func(deleted int, err error) {
+		select {
+		case ticks <- deleted:
+		default:
+		}
+	})
+	select {
+	case deleted := <-ticks:
+		if deleted != 0 {
+			t.Errorf("the tick deleted %d sessions, want none", deleted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tick callback has not been called")
+	}
+}
+
+func TestCleanupErrorHandlerSetWhileRunning(t *testing.T) {
+	store, _ := newTestStore(t)
+	if _, err := store.db.Exec("DROP TABLE sessions"); err != nil {
+		t.Fatal(err)
+	}
+	quit, done, err := store.StartCleanup("", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.StopCleanup(quit, done)
+
+	failures := make(chan error, 1)
+	store.SetCleanupErrorHandler(func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	})
+	select {
+	case err := <-failures:
+		if err == nil {
+			t.Error("the error handler has been called with a nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error handler has not been called")
+	}
+}
+
+func TestCleanupDeletesTheChunksFollowingAPanickingPostDeleteCallback(t *testing.T) {
+	store, clock := newTestStore(t)
+	store.SetCleanupDeleteChunkSize(2)
+	for i := 0; i < 5; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	calls := 0
+	store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {
+		calls++
+		if calls == 1 {
+			panic("bad session")
+		}
+	})
+
+	clock.Advance(2 * time.Minute)
+	deleted, err := store.CleanupNow("")
+	if deleted != 5 || calls != 5 {
+		t.Errorf("deleted %d sessions and called the post-delete callback %d times, want 5 and 5", deleted, calls)
+	}
+	var rowErrs RowErrors
+	if !errors.As(err, &rowErrs) || len(rowErrs) != 1 || !errors.Is(err, ErrCleanupPanicked) {
+		t.Errorf("cleanup returned %v, want a RowErrors with the panic of the callback", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions are left, want none", count)
+	}
+}
+
+func TestCleanupTicksFollowingAPanickingPostDeleteCallback(t *testing.T) {
+	store, clock := newTestStore(t)
+	store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) { panic("bad session") })
+	ticks := make(chan int, 100)
+	store.SetCleanupTickCallback(func(deleted int, err error) {
+		if deleted > 0 {
+			ticks <- deleted
+		}
+	})
+	quit, done, err := store.StartCleanup("", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.StopCleanup(quit, done)
+
+	for tick := 0; tick < 2; tick++ {
+		saveSession(t, store, "session", 60, nil)
+		clock.Advance(2 * time.Minute)
+		select {
+		case deleted := <-ticks:
+			if deleted != 1 {
+				t.Errorf("tick %d deleted %d sessions, want 1", tick, deleted)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no tick deleted the session expired after %d panics", tick)
+		}
+	}
+}
+
+func TestCleanupDryRunPagesTheExpiredSessions(t *testing.T) {
+	defer func(pageSize int) { cleanupPageSize = pageSize }(cleanupPageSize)
+	cleanupPageSize = 2
+
+	store, clock := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	store.SetCleanupDryRun(true)
+	var batches []int
+	store.SetExpiredSessionsBatchCallback(func(expired []*sessions.Session) { batches = append(batches, len(expired)) })
+	var results []CleanupResult
+	store.AddCleanupObserver(func(result CleanupResult) { results = append(results, result) })
+
+	clock.Advance(2 * time.Minute)
+	if _, err := store.CleanupNow(""); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(results) != 1 || results[0].WouldDelete != 5 {
+		t.Errorf("the dry run reported %v, want 5 sessions which would be deleted", results)
+	}
+	if len(batches) != 3 || batches[0] != 2 || batches[1] != 2 || batches[2] != 1 {
+		t.Errorf("the batch callback got pages of %v sessions, want [2 2 1]", batches)
+	}
+}
+
+func TestDeleteCallbacksSetWhileRunning(t *testing.T) {
+	store, clock := newTestStore(t)
+	for i := 0; i < 20; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	clock.Advance(2 * time.Minute)
+	quit, done, err := store.StartCleanup("", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		store.SetExpiredSessionPreDeleteCallback(func(*sessions.Session) {})
+		store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {})
+		store.SetExpiredSessionsBatchCallback(func([]*sessions.Session) {})
+		time.Sleep(100 * time.Microsecond)
+	}
+	store.StopCleanup(quit, done)
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d expired sessions are left, want none", count)
+	}
+}
+
+func TestDeleteWhereDeletesTheSessionsWhosePreDeleteCallbackPanics(t *testing.T) {
+	store, _ := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		saveSession(t, store, "session", 3600, nil)
+	}
+	store.SetExpiredSessionPreDeleteCallback(func(*sessions.Session) { panic("bad session") })
+
+	deleted, err := store.DeleteWhere(func(*sessions.Session) bool { return true })
+	if err != nil || deleted != 3 {
+		t.Errorf("DeleteWhere deleted %d sessions with error %v, want 3 and no error", deleted, err)
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions are left, want none", count)
+	}
+}
+
+// newStoreWithUnreadableRow returns a store whose table, created by the test, has a TEXT ID column accepting NULL, and
+// a row without an ID, which the cleanups can't read, expired before the sessions saved by the test.
+func newStoreWithUnreadableRow(t *testing.T) (*SqliteStore, *sqlitestoretest.FakeClock) {
+	t.Helper()
+	db, err := sql.Open(DefaultDriverName, "file:"+filepath.Join(t.TempDir(), "sessions.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec("CREATE TABLE sessions (id TEXT PRIMARY KEY, session_data LONGBLOB, created_on TIMESTAMP, " +
+		"modified_on TIMESTAMP, expires_on TIMESTAMP, session_name TEXT, owner TEXT, deleted_at TIMESTAMP, " +
+		"last_access TIMESTAMP, client_ip TEXT, user_agent TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO sessions (id, created_on, modified_on, expires_on, session_name) VALUES (NULL, ?, ?, ?, 'session')",
+		testEpoch, testEpoch, testEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	store, err := New(db, "sessions", WithSchema(Schema{SkipTableCreation: true, TextIDs: true}),
+		WithKeyPairs([]byte("test hash key")), WithClock(clock), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, clock
+}
+
+func TestCleanupSelectsTheRowsItCannotReadOnce(t *testing.T) {
+	defer func(pageSize int) { cleanupPageSize = pageSize }(cleanupPageSize)
+	cleanupPageSize = 3
+
+	for _, dryRun := range []bool{false, true} {
+		store, clock := newStoreWithUnreadableRow(t)
+		for i := 0; i < 7; i++ {
+			saveSession(t, store, "session", 60, nil)
+		}
+		store.SetCleanupDryRun(dryRun)
+		store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {})
+		var results []CleanupResult
+		store.AddCleanupObserver(func(result CleanupResult) { results = append(results, result) })
+
+		clock.Advance(2 * time.Minute)
+		_, err := store.CleanupNow("")
+		var rowErrs RowErrors
+		if !errors.As(err, &rowErrs) || len(rowErrs) != 1 || !errors.Is(err, ErrScan) {
+			t.Errorf("dry run %v: cleanup returned %v, want a RowErrors with the error of the unreadable row only", dryRun, err)
+		}
+		if len(results) != 1 || results[0].Deleted+results[0].WouldDelete != 7 {
+			t.Errorf("dry run %v: the cleanup reported %+v, want 7 sessions", dryRun, results)
+		}
+		want := 1
+		if dryRun {
+			want = 8
+		}
+		if count := countRows(t, store, "sessions"); count != want {
+			t.Errorf("dry run %v: %d rows are left, want %d", dryRun, count, want)
+		}
+	}
+}
+
+func TestCleanupQueryDeletesTheExpiredSessions(t *testing.T) {
+	store, clock := newTestStore(t)
+	saveSession(t, store, "session", 60, nil)
+	saveSession(t, store, "other", 60, nil)
+	saveSession(t, store, "session", 3600, nil)
+	clock.Advance(2 * time.Minute)
+
+	query, args := store.CleanupQuery("session")
+	res, err := store.db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("unable to run the cleanup statement %q: %v", query, err)
+	}
+	if deleted, _ := res.RowsAffected(); deleted != 1 {
+		t.Errorf("the cleanup statement deleted %d sessions, want the expired one of the name", deleted)
+	}
+	if count := countRows(t, store, "sessions"); count != 2 {
+		t.Errorf("%d sessions are left, want 2", count)
+	}
+}
@@ -0,0 +1,47 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// SetRecordClient sets whether the IP address and the user agent of the client each new session is created from are
+// stored in the client_ip and user_agent columns (see Schema), e.g. to show them on a security dashboard.
+// They are taken from the client_ip and user_agent string values of the session, if it has them when it is first
+// saved, e.g. because the caller has taken the IP address from the X-Forwarded-For header of a trusted proxy, and from
+// the remote address and the User-Agent header of the request passed to Save otherwise.
+// When enabled, the recorded values are set as the client_ip and user_agent values of the loaded sessions, like the
+// created_on value, and they are never stored along with the other values.
+// The rows of the sessions created while the recording was disabled have no IP address nor user agent. Disabled by
+// default.
+func (m *SqliteStore) SetRecordClient(enabled bool) {
+	m.recordClient = enabled
+}
+
+// clientOf returns the IP address and the user agent to store for the new session, which are NULL if they are
+// unknown or if recording them is disabled, removing them from the values of the session.
+func (m *SqliteStore) clientOf(r *http.Request, session *sessions.Session) (sql.NullString, sql.NullString) {
+	if !m.recordClient {
+		return sql.NullString{}, sql.NullString{}
+	}
+	clientIP, _ := session.Values["client_ip"].(string)
+	userAgent, _ := session.Values["user_agent"].(string)
+	delete(session.Values, "client_ip")
+	delete(session.Values, "user_agent")
+
+	if r != nil {
+		if clientIP == "" {
+			clientIP = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				clientIP = host
+			}
+		}
+		if userAgent == "" {
+			userAgent = r.UserAgent()
+		}
+	}
+	return sql.NullString{String: clientIP, Valid: clientIP != ""}, sql.NullString{String: userAgent, Valid: userAgent != ""}
+}
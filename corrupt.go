@@ -0,0 +1,42 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrSessionCorrupt wraps the errors returned when the stored data of a session can't be decoded, e.g. because it is
+// corrupt or it has been written by an incompatible serializer.
+var ErrSessionCorrupt = errors.New("Session data corrupt")
+
+// SetDropCorruptSessions sets whether New, and so Get, treat a session whose stored data can't be decoded (see
+// ErrSessionCorrupt) as a session which doesn't exist: its row is deleted, recording it in the audit log as deleted
+// for DeletionCorrupt, and a new session is returned, so that a single corrupt row doesn't lock its user out forever.
+// Each dropped session is logged, and its error is passed to the cleanup error handler, if set.
+// Beware that a wrong encryption key (see SetEncryptionKey) makes every encrypted session undecodable, so all of them
+// would be dropped. Disabled by default, in which case New returns the error along with a new session, leaving the
+// row untouched.
+func (m *SqliteStore) SetDropCorruptSessions(enabled bool) {
+	m.dropCorruptSessions = enabled
+}
+
+// dropCorruptSession deletes the session which failed to load with err, if it is corrupt and dropping the corrupt
+// sessions is enabled, resetting it to a new session. It returns the error New must return, which is nil if the
+// session has been dropped.
+func (m *SqliteStore) dropCorruptSession(ctx context.Context, session *sessions.Session, err error) error {
+	if !m.dropCorruptSessions || !errors.Is(err, ErrSessionCorrupt) {
+		return err
+	}
+
+	m.log().Warn("Dropping corrupt session", "session_id", session.ID, "session_name", session.Name(), "error", err)
+	if _, delErr := m.deleteRow(ctx, DeletionCorrupt, session.ID, session.Name()); delErr != nil {
+		return fmt.Errorf("unable to drop the corrupt session %s: %w", session.ID, delErr)
+	}
+	m.reportCleanupError(fmt.Errorf("dropped the corrupt session %s: %w", session.ID, err))
+	session.ID = ""
+	session.Values = make(map[interface{}]interface{})
+	return nil
+}
@@ -0,0 +1,97 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gorilla/sessions"
+)
+
+// DeleteAll deletes every session named sessionName, expired or not, and returns the number of sessions actually
+// deleted, e.g. to log every user out. An empty sessionName deletes the sessions of every name, like
+// DeleteAllSessions. The pre-delete and post-delete callbacks, if set, are called for the deleted sessions exactly like
+// the cleanup does for the expired sessions. The sessions kept in the tables of their names (see SetTableForName) are
+// deleted as well.
+func (m *SqliteStore) DeleteAll(sessionName string) (int, error) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.DeleteAll(sessionName)
+	}
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	deleted, err := m.deleteAll(context.Background(), sessionName)
+	if sessionName == "" {
+		//the sessions of every name are deleted, including the ones stored in the tables of their names
+		for _, shard := range m.allShards() {
+			shardDeleted, shardErr := shard.DeleteAll("")
+			deleted += shardDeleted
+			err = errors.Join(err, shardErr)
+		}
+	}
+	return deleted, err
+}
+
+// deleteAll deletes every session named sessionName, or of every name if it is empty, from the table of the store.
+func (m *SqliteStore) deleteAll(ctx context.Context, sessionName string) (int, error) {
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+
+	if !m.observesDeletions() {
+		//nobody needs to see the sessions before they are gone, so delete all of them with a single statement
+		return m.execDelete(ctx, DeletionBulk, " WHERE 1"+nameCond, nameArgs...)
+	}
+
+	ids, names, loaded, err := m.getSessionsIdsAndCallCallbacks(ctx, sessionName,
+		"SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+" FROM "+m.table+" WHERE 1"+m.schema.liveCondition()+nameCond, nameArgs...)
+	if err != nil {
+		return 0, err
+	}
+	return m.deleteSessionsWithIds(ctx, ids, names, loaded, SessionDeleted, DeletionBulk, nil)
+}
+
+// DeleteAllSessions deletes every session of every name, expired or not, and returns the number of sessions actually
+// deleted, e.g. to wipe the store in the teardown of a test.
+func (m *SqliteStore) DeleteAllSessions() (int, error) {
+	return m.DeleteAll("")
+}
+
+// DeleteWhere deletes the sessions of every name, expired or not, for which match returns true, and returns the number
+// of sessions actually deleted, e.g. to evict the sessions created by a deprecated version of the application.
+// The pre-delete and post-delete callbacks, if set, are called for the deleted sessions exactly like the cleanup does
+// for the expired sessions.
+// Every session is loaded and decoded, a page at a time like ForEachSession does, and the matching ones are deleted
+// a page at a time as well, so this is meant for occasional maintenance rather than for the hot paths. When an error
+// occurs, the sessions matched until then may have been deleted already.
+func (m *SqliteStore) DeleteWhere(match func(*sessions.Session) bool) (int, error) {
+	ctx := context.Background()
+	var ids, names []string
+	var matched []*sessions.Session
+	deleted := 0
+
+	//deletes the sessions matched so far
+	flush := func() error {
+		n, err := m.deleteSessionsWithIds(ctx, ids, names, matched, SessionDeleted, DeletionBulk, nil)
+		deleted += n
+		ids, names, matched = ids[:0], names[:0], matched[:0]
+		return err
+	}
+
+	err := m.ForEachSession("", func(session *sessions.Session) error {
+		if !match(session) {
+			return nil
+		}
+		if preDeleteCallback, _ := m.deleteCallbacks(); preDeleteCallback != nil {
+			m.callCleanupCallback(session.ID, nil, func() { preDeleteCallback(session) })
+		}
+		ids = append(ids, session.ID)
+		names = append(names, session.Name())
+		matched = append(matched, session)
+		if len(ids) >= forEachPageSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return deleted, err
+}
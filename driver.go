@@ -0,0 +1,58 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// DefaultDriverName is the name of the database/sql driver NewSqliteStore opens the database with, the one registered
+// by github.com/mattn/go-sqlite3. Use NewSqliteStoreWithDriver to open it with another driver, such as the "sqlite"
+// driver of modernc.org/sqlite, which doesn't need cgo.
+const DefaultDriverName = "sqlite3"
+
+// timestampFormat is the format the timestamps are stored with. It is the one go-sqlite3 stores the time.Time values
+// with, and SQLite's date and time functions understand it.
+const timestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// SQLite result codes the store handles, see https://www.sqlite.org/rescode.html.
+const (
+	sqliteBusy                 = 5
+	sqliteLocked               = 6
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// NewSqliteStoreWithDriver is like NewSqliteStore, but it opens the database with the database/sql driver registered as
+// driverName, which must be a SQLite driver and must have been imported by the caller.
+func NewSqliteStoreWithDriver(driverName string, endpoint string, tableName string, sessionsOptions sessions.Options, keyPairs ...[]byte) (*SqliteStore, error) {
+	db, err := sql.Open(driverName, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSqliteStoreFromConnection(db, tableName, sessionsOptions, keyPairs...)
+}
+
+// timestamp formats t to be bound to a statement. The timestamps are formatted by the store rather than by the driver,
+// as not every driver formats the time.Time values the way SQLite's date and time functions expect: modernc.org/sqlite
+// stores them as time.Time.String() by default.
+func timestamp(t time.Time) string {
+	return t.Format(timestampFormat)
+}
+
+// sqliteErrorCode returns the extended result code of err, if it has been returned by SQLite. Besides go-sqlite3,
+// whose errors are only available when it is built with cgo, the errors with a Code method returning the extended
+// result code are recognized, like the ones of modernc.org/sqlite.
+func sqliteErrorCode(err error) (int, bool) {
+	if code, ok := driverErrorCode(err); ok {
+		return code, true
+	}
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) {
+		return coder.Code(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,44 @@
+//go:build cgo
+
+package sqlitestore
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverErrorCode returns the extended result code of err, if it is a go-sqlite3 error.
+func driverErrorCode(err error) (int, bool) {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return int(sqliteErr.ExtendedCode), true
+	}
+	return 0, false
+}
+
+// backupDriverConn copies the main database of driverConn to a new database at destPath with the online backup API,
+// if it is a go-sqlite3 connection, returning false otherwise.
+func backupDriverConn(driverConn interface{}, destPath string) (bool, error) {
+	src, ok := driverConn.(*sqlite3.SQLiteConn)
+	if !ok {
+		return false, nil
+	}
+	destConn, err := (&sqlite3.SQLiteDriver{}).Open(destPath)
+	if err != nil {
+		return true, err
+	}
+	dest := destConn.(*sqlite3.SQLiteConn)
+	defer dest.Close()
+
+	backup, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return true, err
+	}
+	//a single step copies every page while holding a read transaction on the source, so the copy is consistent
+	if _, err = backup.Step(-1); err != nil {
+		backup.Finish()
+		return true, err
+	}
+	return true, backup.Finish()
+}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package sqlitestore
+
+// driverErrorCode recognizes no error, as go-sqlite3 is not available without cgo.
+func driverErrorCode(err error) (int, bool) {
+	return 0, false
+}
+
+// backupDriverConn recognizes no connection, as go-sqlite3 is not available without cgo.
+func backupDriverConn(driverConn interface{}, destPath string) (bool, error) {
+	return false, nil
+}
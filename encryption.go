@@ -0,0 +1,75 @@
+package sqlitestore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrEncryptionKeyMissing is returned when loading a session whose data has been encrypted, but no encryption key
+	// has been set.
+	ErrEncryptionKeyMissing = errors.New("Session data is encrypted, but no encryption key has been set")
+	// ErrDecryptionFailed is returned when the encrypted data of a session can't be decrypted with the encryption key,
+	// e.g. because it has been encrypted with a different key.
+	ErrDecryptionFailed = errors.New("Unable to decrypt the session data, the encryption key may be wrong")
+)
+
+// SetEncryptionKey sets the key used to encrypt the session data before it is stored, with AES-GCM and a random nonce
+// for each write. The key must be 16, 24 or 32 bytes long, to select AES-128, AES-192 or AES-256 respectively,
+// while a nil or empty key disables the encryption. The data is compressed, if enabled, before being encrypted.
+//
+// The sessions stored without encryption can still be loaded after a key has been set, while loading a session
+// which has been encrypted fails with ErrEncryptionKeyMissing if no key is set, and with ErrDecryptionFailed if it
+// has been encrypted with a different key.
+func (m *SqliteStore) SetEncryptionKey(key []byte) error {
+	if len(key) == 0 {
+		m.aead = nil
+		return nil
+	}
+	if m.jsonText {
+		return ErrJSONTextConflict
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key, it must be 16, 24 or 32 bytes long: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	m.aead = aead
+	return nil
+}
+
+// encrypt encrypts data and wraps it, along with its nonce, in an encrypted envelope.
+func (m *SqliteStore) encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate the encryption nonce: %w", err)
+	}
+
+	sealed := append([]byte{envelopeMarker, envelopeEncrypted}, nonce...)
+	return m.aead.Seal(sealed, nonce, data, nil), nil
+}
+
+// decrypt decrypts the data of an encrypted envelope.
+func (m *SqliteStore) decrypt(data []byte) ([]byte, error) {
+	if m.aead == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	if len(data) < m.aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce, ciphertext := data[:m.aead.NonceSize()], data[m.aead.NonceSize():]
+	plaintext, err := m.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,39 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	store, _ := newTestStore(t, WithEncryptionKey(bytes.Repeat([]byte{1}, 32)))
+	store.SetCompression(true)
+	value := strings.Repeat("secret ", 1000)
+	saved := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"value": value})
+
+	if data := storedData(t, store, saved.ID); bytes.Contains(data, []byte("secret")) {
+		t.Fatal("the stored data is not encrypted")
+	}
+	loaded, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatalf("unable to load the encrypted session: %v", err)
+	}
+	if loaded.Values["value"] != value {
+		t.Error("the decrypted value is not the saved one")
+	}
+
+	if err = store.SetEncryptionKey(bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetByID("session", saved.ID); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("loading with another key returned %v, want ErrDecryptionFailed", err)
+	}
+	if err = store.SetEncryptionKey(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetByID("session", saved.ID); !errors.Is(err, ErrEncryptionKeyMissing) {
+		t.Errorf("loading without a key returned %v, want ErrEncryptionKeyMissing", err)
+	}
+}
@@ -0,0 +1,155 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gorilla/sessions"
+)
+
+// The serialized session values may be wrapped in envelopes before being stored, e.g. when they are compressed.
+// An envelope is made of envelopeMarker, a byte identifying the kind of envelope and the wrapped data.
+// None of the built-in serializers produces data starting with envelopeMarker, so the data which doesn't start with it
+// is the serialized values themselves, as stored by the versions of the store which had no envelopes.
+const envelopeMarker = 0x00
+
+// New kinds of envelopes, e.g. for a new encryption scheme, must be identified by new bytes, so that the data stored
+// in the older envelopes can still be unwrapped.
+const (
+	// the wrapped data has been compressed with compress/flate
+	envelopeCompressed = 'z'
+	// the wrapped data is the nonce followed by the data encrypted with AES-GCM
+	envelopeEncrypted = 'e'
+	// the wrapped data is a byte identifying its format (see FormatSerializer) followed by the serialized values, it is
+	// always the innermost envelope
+	envelopeFormat = 'f'
+)
+
+// defaultCompressionThreshold is the default size of the serialized values from which they are compressed.
+const defaultCompressionThreshold = 512
+
+// SetCompression sets whether the serialized session values are compressed before being stored, which is only done
+// when they are at least as large as the threshold set with SetCompressionThreshold.
+// The compressed data is recognized on load regardless of this setting, so it can be enabled or disabled at any
+// time without making the sessions which are already stored unreadable. Disabled by default.
+func (m *SqliteStore) SetCompression(enabled bool) {
+	m.compression = enabled
+}
+
+// SetCompressionThreshold sets the size in bytes from which the serialized session values are compressed.
+// A value <= 0 restores the default (512 bytes).
+func (m *SqliteStore) SetCompressionThreshold(size int) {
+	m.compressionThreshold = size
+}
+
+// ErrValueTooLarge is returned by Save when the session values are larger than the size set with SetMaxValueSize.
+var ErrValueTooLarge = errors.New("Session values too large")
+
+// SetMaxValueSize sets the maximum size in bytes of the serialized session values, measured after they have been
+// compressed if compression is enabled. Save returns an error wrapping ErrValueTooLarge instead of storing larger
+// values. A value <= 0 means that the size is unlimited, which is the default.
+func (m *SqliteStore) SetMaxValueSize(size int) {
+	m.maxValueSize = size
+}
+
+// SetValueSizeObserver sets a function which gets called on every Save with the ID of the session and the size in bytes
+// of its serialized values, measured like SetMaxValueSize does, e.g. to histogram the sizes of the sessions and
+// right-size their limit. It is called before the size is checked against the limit, so it also sees the sizes of the
+// values which are rejected as too large. The ID is empty for the sessions being saved for the first time, as they
+// get it once inserted. The observer must be fast, as it runs within Save.
+func (m *SqliteStore) SetValueSizeObserver(observer func(id string, size int)) {
+	m.valueSizeObserver = observer
+}
+
+// encode serializes the session values into the data to store, wrapping it in the configured envelopes.
+func (m *SqliteStore) encode(session *sessions.Session) ([]byte, error) {
+	if m.jsonText {
+		return m.encodeJSONText(session)
+	}
+	data, err := m.serialize(session)
+	if err != nil {
+		return nil, err
+	}
+	if format, ok := m.format(); ok {
+		data = append([]byte{envelopeMarker, envelopeFormat, format}, data...)
+	}
+
+	threshold := m.compressionThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	if m.compression && len(data) >= threshold {
+		if data, err = compress(data); err != nil {
+			return nil, err
+		}
+	}
+	if m.valueSizeObserver != nil {
+		m.valueSizeObserver(session.ID, len(data))
+	}
+	if m.maxValueSize > 0 && len(data) > m.maxValueSize {
+		return nil, fmt.Errorf("%w: session %q is %d bytes, the maximum is %d bytes", ErrValueTooLarge, session.Name(), len(data), m.maxValueSize)
+	}
+	if m.aead != nil {
+		if data, err = m.encrypt(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// decode unwraps the stored data from its envelopes and deserializes the session values from it.
+func (m *SqliteStore) decode(data []byte, session *sessions.Session) error {
+	for len(data) >= 2 && data[0] == envelopeMarker {
+		var err error
+		switch data[1] {
+		case envelopeCompressed:
+			data, err = decompress(data[2:])
+		case envelopeEncrypted:
+			data, err = m.decrypt(data[2:])
+		case envelopeFormat:
+			if len(data) < 3 {
+				return errors.New("truncated session data format envelope")
+			}
+			return m.deserializeFormat(data[2], data[3:], session)
+		default:
+			err = fmt.Errorf("unknown session data envelope %q", data[1])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.deserialize(data, session)
+}
+
+// compress compresses data and wraps it in a compressed envelope.
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{envelopeMarker, envelopeCompressed})
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses the data of a compressed envelope.
+func decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress the session data: %w", err)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,42 @@
+package sqlitestore
+
+import (
+	"strings"
+	"testing"
+)
+
+// storedData returns the data stored for the session with the given ID.
+func storedData(t *testing.T, store *SqliteStore, id string) []byte {
+	t.Helper()
+	stmt, err := store.db.Prepare("SELECT session_data FROM sessions WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var data []byte
+	if err = stmt.QueryRow(id).Scan(&data); err != nil {
+		t.Fatalf("unable to read the data of the session %s: %v", id, err)
+	}
+	return data
+}
+
+func TestLargeValueRoundTrip(t *testing.T) {
+	value := strings.Repeat("large session value ", 5000)
+	for _, compression := range []bool{false, true} {
+		store, _ := newTestStore(t)
+		store.SetCompression(compression)
+		saved := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"value": value})
+
+		loaded, err := store.GetByID("session", saved.ID)
+		if err != nil {
+			t.Fatalf("unable to load the session with compression %t: %v", compression, err)
+		}
+		if loaded.Values["value"] != value {
+			t.Errorf("the value loaded with compression %t is not the saved one", compression)
+		}
+		data := storedData(t, store, saved.ID)
+		if compressed := len(data) >= 2 && data[0] == envelopeMarker && data[1] == envelopeCompressed; compressed != compression {
+			t.Errorf("the stored data is compressed: %t, want %t", compressed, compression)
+		}
+	}
+}
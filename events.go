@@ -0,0 +1,103 @@
+package sqlitestore
+
+import "context"
+
+// SessionEventType tells what happened to the session of a SessionEvent.
+type SessionEventType int
+
+const (
+	// SessionCreated is emitted when a new session has been stored.
+	SessionCreated SessionEventType = iota
+	// SessionSaved is emitted when an existing session has been stored again.
+	SessionSaved
+	// SessionDeleted is emitted when a session has been deleted, either explicitly or because its owner has too many.
+	SessionDeleted
+	// SessionExpired is emitted when an expired session has been deleted by a cleanup.
+	SessionExpired
+)
+
+func (t SessionEventType) String() string {
+	switch t {
+	case SessionCreated:
+		return "created"
+	case SessionSaved:
+		return "saved"
+	case SessionDeleted:
+		return "deleted"
+	case SessionExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// SessionEvent describes a change of a session stored by the store. SessionName is empty when the session has been
+// deleted by ID, without its name being known.
+type SessionEvent struct {
+	Type        SessionEventType
+	SessionID   string
+	SessionName string
+}
+
+// eventsBufferSize is the number of events each subscriber can lag behind before the events start being dropped.
+const eventsBufferSize = 256
+
+// Events subscribes to the changes of the sessions stored by the store, returning the channel the events are sent
+// to. Each call returns a new channel, which receives every event emitted after the call. The events are sent without
+// blocking, so that a slow subscriber can't stall the store: the channel holds up to 256 events, the ones that don't
+// fit are dropped. The channel is closed when the store is closed.
+//
+// Subscribing makes the cleanups select the IDs of the expired sessions before deleting them, as they do when the
+// expired session callbacks are set, so that their Expired events can be emitted.
+func (m *SqliteStore) Events() <-chan SessionEvent {
+	events := make(chan SessionEvent, eventsBufferSize)
+	m.subscribersMu.Lock()
+	defer m.subscribersMu.Unlock()
+	if m.closed.Load() {
+		close(events)
+		return events
+	}
+	m.subscribers = append(m.subscribers, events)
+	return events
+}
+
+// emit sends the event to every subscriber, dropping it for the ones whose channel is full. Within a transaction (see
+// atomically), the event is only sent once the transaction has been committed.
+func (m *SqliteStore) emit(ctx context.Context, eventType SessionEventType, id string, name string) {
+	if state := txFrom(ctx); state != nil {
+		state.events = append(state.events, SessionEvent{Type: eventType, SessionID: id, SessionName: name})
+		return
+	}
+	m.subscribersMu.Lock()
+	defer m.subscribersMu.Unlock()
+	for _, events := range m.subscribers {
+		select {
+		case events <- SessionEvent{Type: eventType, SessionID: id, SessionName: name}:
+		default:
+			m.log().Warn("Session event dropped, the subscriber is too slow", "type", eventType, "session_id", id, "session_name", name)
+		}
+	}
+}
+
+// hasSubscribers reports whether anybody subscribed to the events.
+func (m *SqliteStore) hasSubscribers() bool {
+	m.subscribersMu.Lock()
+	defer m.subscribersMu.Unlock()
+	return len(m.subscribers) > 0
+}
+
+// observesDeletions reports whether somebody needs to know which sessions are deleted, either through the expired
+// session callbacks or through the events, in which case the sessions can't be deleted by a single statement.
+func (m *SqliteStore) observesDeletions() bool {
+	preDeleteCallback, postDeleteCallback := m.deleteCallbacks()
+	return preDeleteCallback != nil || postDeleteCallback != nil || m.batchCallback() != nil || m.hasSubscribers()
+}
+
+// closeEvents closes the channels of the subscribers, once the store has been closed.
+func (m *SqliteStore) closeEvents() {
+	m.subscribersMu.Lock()
+	defer m.subscribersMu.Unlock()
+	for _, events := range m.subscribers {
+		close(events)
+	}
+	m.subscribers = nil
+}
@@ -0,0 +1,139 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// SetExpiryGracePeriod sets how long after their expiry the sessions are still considered active, e.g. to tolerate
+// the clock skew between the application servers, which would otherwise log the users out a few seconds early. The
+// sessions are considered expired only once they have expired more than grace before now by all the operations of
+// the store: the loads, the existence checks, the counts and the cleanups. A grace <= 0 disables it, which is the
+// default.
+func (m *SqliteStore) SetExpiryGracePeriod(grace time.Duration) {
+	m.expiryGrace = max(grace, 0)
+}
+
+// expiryCutoff returns the time the sessions must be expired before to be considered expired, which is now unless a
+// grace period has been set with SetExpiryGracePeriod.
+func (m *SqliteStore) expiryCutoff() time.Time {
+	return m.now().Add(-m.expiryGrace)
+}
+
+// ErrExpiryInPast is returned by SaveWithExpiry when the expiry it is given has already passed.
+var ErrExpiryInPast = errors.New("Expiry in the past")
+
+// SaveWithExpiry saves the session like Save does, but it makes it expire at expiresAt rather than MaxAge after now,
+// e.g. for the "remember me" sessions or the sessions migrated from another store, which must expire when they were
+// due to. The MaxAge of the session is set to the number of seconds until expiresAt, rounded up, so that the cookie
+// expires along with the stored session. The following saves of the session derive its expiry from MaxAge again,
+// like they do for any session with its own MaxAge, unless they pass the expiry again.
+//
+// ErrExpiryInPast is returned, and nothing is saved, if expiresAt is not after now, rather than storing a session which
+// is expired already: use Delete to delete a session.
+func (m *SqliteStore) SaveWithExpiry(r *http.Request, w http.ResponseWriter, session *sessions.Session, expiresAt time.Time) error {
+	untilExpiry := expiresAt.Sub(m.now())
+	if untilExpiry <= 0 {
+		return fmt.Errorf("%w: %s", ErrExpiryInPast, expiresAt.Format(time.RFC3339Nano))
+	}
+	session.Options.MaxAge = int((untilExpiry + time.Second - 1) / time.Second)
+	_, err := m.saveContext(context.WithValue(r.Context(), expiryContextKey{}, expiresAt.In(m.location())), r, w, session)
+	return err
+}
+
+// expiryContextKey is the key of the context value which carries the expiry passed to SaveWithExpiry.
+type expiryContextKey struct{}
+
+// expiryFrom returns the expiry the session saved with ctx must be stored with, false if it must be derived from its
+// MaxAge.
+func expiryFrom(ctx context.Context) (time.Time, bool) {
+	expiresAt, ok := ctx.Value(expiryContextKey{}).(time.Time)
+	return expiresAt, ok
+}
+
+// ExtendExpiry pushes the expiry of the sessions with the given IDs forward by by, e.g. to keep all the sessions of a
+// user alive after a sensitive action, and returns the number of sessions actually extended. The expired sessions are
+// left as they are, to be deleted by the cleanup, see ExtendExpiryEvenIfExpired. The IDs are bound at most as many at
+// a time as the cleanup deletes (see SetCleanupDeleteChunkSize), all in a single transaction, and the sessions named
+// after a name mapped to a table of its own (see SetTableForName) must be extended with the store of that table.
+// The expiry keeps the UTC offset it has been stored with, and it is extended with a precision of a millisecond.
+func (m *SqliteStore) ExtendExpiry(ids []string, by time.Duration) (int, error) {
+	return m.extendExpiry(ids, by, false)
+}
+
+// ExtendExpiryEvenIfExpired is like ExtendExpiry, but it extends the expired sessions as well, which become active
+// again if their new expiry is in the future.
+func (m *SqliteStore) ExtendExpiryEvenIfExpired(ids []string, by time.Duration) (int, error) {
+	return m.extendExpiry(ids, by, true)
+}
+
+func (m *SqliteStore) extendExpiry(ids []string, by time.Duration, extendExpired bool) (int, error) {
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	if by <= 0 {
+		return 0, fmt.Errorf("invalid expiry extension %v, it must be positive", by)
+	}
+	chunkSize := m.cleanupDeleteChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultDeleteChunkSize
+	}
+
+	//the wall clock time of the expiry is extended and its UTC offset, if any, appended back to it, so that the
+	//expiry stays in the time zone it has been stored in, see timestampFormat
+	expires := m.schema.ExpiresOnColumn
+	extended := "CASE WHEN " + expires + " GLOB '*[+-][0-9][0-9]:[0-9][0-9]'" +
+		" THEN strftime('%Y-%m-%d %H:%M:%f', julianday(substr(" + expires + ", 1, length(" + expires + ") - 6)) + ?) || substr(" + expires + ", -6)" +
+		" ELSE strftime('%Y-%m-%d %H:%M:%f', julianday(" + expires + ") + ?) END"
+	days := by.Hours() / 24
+	condition := m.schema.liveCondition()
+	var conditionArgs []interface{}
+	if !extendExpired {
+		condition = " AND julianday(" + expires + ") >= julianday(?)" + condition
+		conditionArgs = []interface{}{timestamp(m.expiryCutoff())}
+	}
+
+	updated := 0
+	err := m.atomically(context.Background(), func(ctx context.Context) error {
+		for start := 0; start < len(ids); start += chunkSize {
+			chunk := ids[start:min(start+chunkSize, len(ids))]
+			//like the deletions of the cleanup, the IDs are bound to a number of placeholders rounded up to a power
+			//of two, repeating the last ID, so that the statements get cached for a few sizes only
+			placeholders := 1
+			for placeholders < len(chunk) {
+				placeholders *= 2
+			}
+			placeholders = min(placeholders, chunkSize)
+			stmt, err := m.stmt(ctx, "UPDATE "+m.table+" SET "+expires+" = "+extended+
+				" WHERE "+m.schema.IDColumn+" IN (?"+strings.Repeat(", ?", placeholders-1)+")"+condition)
+			if err != nil {
+				return err
+			}
+			args := []interface{}{days, days}
+			for i := 0; i < placeholders; i++ {
+				args = append(args, chunk[min(i, len(chunk)-1)])
+			}
+			res, err := m.execRetry(ctx, stmt, append(args, conditionArgs...)...)
+			if err != nil {
+				return err
+			}
+			if affected, err := res.RowsAffected(); err == nil {
+				updated += int(affected)
+			}
+			for _, id := range chunk {
+				m.invalidateCached(ctx, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, m.handleError(context.Background(), err)
+	}
+	return updated, nil
+}
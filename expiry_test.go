@@ -0,0 +1,36 @@
+package sqlitestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtendExpiry(t *testing.T) {
+	store, clock := newTestStore(t)
+	store.SetCleanupDeleteChunkSize(2)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, saveSession(t, store, "session", 3600, nil).ID)
+	}
+	expired := saveSession(t, store, "session", 60, nil)
+	clock.Advance(2 * time.Minute)
+
+	extended, err := store.ExtendExpiry(append(ids, expired.ID), time.Hour)
+	if err != nil || extended != 3 {
+		t.Errorf("extended %d sessions with error %v, want the 3 active ones and no error", extended, err)
+	}
+	session, err := store.GetByID("session", ids[2])
+	if err != nil {
+		t.Fatalf("unable to load an extended session: %v", err)
+	}
+	if got, _ := session.Values["expires_on"].(time.Time); !got.Equal(testEpoch.Add(2 * time.Hour)) {
+		t.Errorf("the extended session expires on %v, want %v", got, testEpoch.Add(2*time.Hour))
+	}
+
+	if extended, err = store.ExtendExpiryEvenIfExpired([]string{expired.ID}, time.Hour); err != nil || extended != 1 {
+		t.Errorf("extended %d expired sessions with error %v, want 1 and no error", extended, err)
+	}
+	if _, err = store.GetByID("session", expired.ID); err != nil {
+		t.Errorf("loading the revived session failed: %v", err)
+	}
+}
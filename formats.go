@@ -0,0 +1,150 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// FormatSerializer is a Serializer which identifies the format of the data it produces with a byte, which is stored
+// along with the data in a format envelope, so that the data can still be decoded after the serializer of the store
+// has been changed, see RegisterSerializer. The serializers of this module use lowercase letters as their formats,
+// the other serializers should use the other bytes.
+type FormatSerializer interface {
+	Serializer
+	Format() byte
+}
+
+// formatCodecs is the format of the values encoded with the store codecs, which is the default when no serializer has
+// been set.
+const formatCodecs = 'c'
+
+// Format returns the format of the data serialized with gob.
+func (GobSerializer) Format() byte { return 'g' }
+
+// Format returns the format of the data serialized as JSON.
+func (JSONSerializer) Format() byte { return 'j' }
+
+// RegisterSerializer registers a serializer to decode the data stored in its format, such as the serializer which had
+// been set before the current one, so that a deployment can read the sessions stored in the old format while storing
+// the new ones in the current format, e.g. to change the serializer without logging everybody out. The codecs, gob
+// and JSON formats are always decoded, as well as the format of the current serializer.
+//
+// Only the data stored since the formats have been recorded is in a format envelope: the data stored by the older
+// versions of the store is decoded with the current serializer, so it must be rewritten with MigrateValues before the
+// serializer is changed.
+func (m *SqliteStore) RegisterSerializer(serializer FormatSerializer) {
+	if m.serializers == nil {
+		m.serializers = make(map[byte]Serializer)
+	}
+	m.serializers[serializer.Format()] = serializer
+}
+
+// format returns the format of the data serialized by the current serializer, false if the serializer doesn't have
+// one, in which case the data isn't wrapped in a format envelope.
+func (m *SqliteStore) format() (byte, bool) {
+	if m.serializer == nil {
+		return formatCodecs, true
+	}
+	if serializer, ok := m.serializer.(FormatSerializer); ok {
+		return serializer.Format(), true
+	}
+	return 0, false
+}
+
+// deserializeFormat decodes the session values from data serialized in format.
+func (m *SqliteStore) deserializeFormat(format byte, data []byte, session *sessions.Session) error {
+	if format == formatCodecs {
+		return securecookie.DecodeMulti(session.Name(), string(data), &session.Values, m.valueCodecs()...)
+	}
+	if serializer, ok := m.serializer.(FormatSerializer); ok && serializer.Format() == format {
+		return serializer.Deserialize(data, session)
+	}
+	if serializer, ok := m.serializers[format]; ok {
+		return serializer.Deserialize(data, session)
+	}
+	for _, serializer := range []FormatSerializer{GobSerializer{}, JSONSerializer{}} {
+		if serializer.Format() == format {
+			return serializer.Deserialize(data, session)
+		}
+	}
+	return fmt.Errorf("unknown session data format %q, its serializer must be registered with RegisterSerializer", format)
+}
+
+// MigrateValues rewrites the stored values of every session, expired or not, in the current format, i.e. serialized
+// with the current serializer and compressed and encrypted as currently configured, e.g. once the serializer has been
+// changed or the encryption has been enabled, so that the old formats don't need to be decoded anymore. It returns
+// the number of sessions rewritten. The sessions which can't be decoded are logged and left untouched, as well as the
+// ones modified while being rewritten, which have been stored in the current format anyway.
+// The sessions are rewritten a page at a time like ForEachSession does, so this is meant for occasional maintenance.
+func (m *SqliteStore) MigrateValues() (int, error) {
+	ctx := context.Background()
+	query := "SELECT " + m.schema.IDColumn + ", " + m.schema.NameColumn + " FROM " + m.table +
+		" WHERE (? IS NULL OR " + m.schema.IDColumn + " > ?)" + m.schema.liveCondition() + " ORDER BY " + m.schema.IDColumn + " LIMIT ?"
+	updateQuery := "UPDATE " + m.table + " SET " + m.schema.DataColumn + " = ? WHERE " + m.schema.IDColumn + " = ?" +
+		" AND julianday(" + m.schema.ModifiedOnColumn + ") = julianday(?)" + m.schema.liveCondition()
+
+	migrated := 0
+	var after interface{}
+	for {
+		if m.closed.Load() {
+			return migrated, ErrStoreClosed
+		}
+		ids, names, err := m.selectSessionsIdsAndNames(ctx, "", query, after, after, forEachPageSize)
+		if err != nil {
+			return migrated, err
+		}
+
+		for i, id := range ids {
+			session := m.newStoredSession(id, names[i])
+			if err = m.load(ctx, session, true); err != nil {
+				if errors.Is(err, ErrSessionNotFound) {
+					//deleted in the meantime
+					continue
+				}
+				if errors.Is(err, ErrSessionCorrupt) {
+					m.log().Warn("Unable to decode the session to migrate, leaving it untouched", "session_id", id, "error", err)
+					continue
+				}
+				return migrated, fmt.Errorf("unable to load the session %s: %w", id, err)
+			}
+
+			//the timestamps are stored in their own columns, not along with the values, like the client when it is
+			//recorded
+			modifiedOn, _ := session.Values["modified_on"].(time.Time)
+			delete(session.Values, "created_on")
+			delete(session.Values, "expires_on")
+			delete(session.Values, "modified_on")
+			if m.recordClient {
+				delete(session.Values, "client_ip")
+				delete(session.Values, "user_agent")
+			}
+			encoded, err := m.encode(session)
+			if err != nil {
+				return migrated, fmt.Errorf("unable to encode the session %s: %w", id, err)
+			}
+
+			stmt, err := m.stmt(ctx, updateQuery)
+			if err != nil {
+				return migrated, err
+			}
+			res, err := m.execRetry(ctx, stmt, m.dataArg(encoded), id, timestamp(modifiedOn))
+			if err != nil {
+				return migrated, err
+			}
+			if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+				m.invalidateCached(ctx, id)
+				migrated++
+			}
+		}
+
+		if len(ids) < forEachPageSize {
+			return migrated, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
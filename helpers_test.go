@@ -0,0 +1,102 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/maxbarbieri/sqlitestore/sqlitestoretest"
+)
+
+// testEpoch is the time the fake clocks of the tests start from.
+var testEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestStore returns a store keeping the sessions in a new in-memory database, with a fake clock set to testEpoch
+// and a logger which discards everything. The store is closed when the test ends.
+func newTestStore(t *testing.T, opts ...Option) (*SqliteStore, *sqlitestoretest.FakeClock) {
+	t.Helper()
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	opts = append([]Option{
+		WithKeyPairs([]byte("test hash key")),
+		WithClock(clock),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	store, err := NewInMemory(opts...)
+	if err != nil {
+		t.Fatalf("unable to create the store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, clock
+}
+
+// newFileTestStore is like newTestStore, but the sessions are kept in a database file in WAL mode, whose writers wait
+// for each other rather than failing like the ones of the in-memory databases do.
+func newFileTestStore(t *testing.T, opts ...Option) (*SqliteStore, *sqlitestoretest.FakeClock) {
+	t.Helper()
+	db, err := sql.Open(DefaultDriverName, "file:"+filepath.Join(t.TempDir(), "sessions.db")+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	opts = append([]Option{
+		WithKeyPairs([]byte("test hash key")),
+		WithClock(clock),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	store, err := New(db, "sessions", opts...)
+	if err != nil {
+		t.Fatalf("unable to create the store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, clock
+}
+
+// newRequest returns a request carrying the cookies set by w, if not nil.
+func newRequest(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	if w != nil {
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+	}
+	return r
+}
+
+// saveSession saves a new session named name with the given values, expiring maxAge seconds after now, and returns it.
+func saveSession(t *testing.T, store *SqliteStore, name string, maxAge int, values map[interface{}]interface{}) *sessions.Session {
+	t.Helper()
+	session, err := store.New(newRequest(nil), name)
+	if err != nil {
+		t.Fatalf("unable to create the session: %v", err)
+	}
+	session.Options.MaxAge = maxAge
+	for key, value := range values {
+		session.Values[key] = value
+	}
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("unable to save the session: %v", err)
+	}
+	return session
+}
+
+// countRows returns the number of rows of the table.
+func countRows(t *testing.T, store *SqliteStore, table string) int {
+	t.Helper()
+	stmt, err := store.db.Prepare("SELECT COUNT(*) FROM `" + table + "`")
+	if err != nil {
+		t.Fatalf("unable to count the rows of %s: %v", table, err)
+	}
+	defer stmt.Close()
+	var count int
+	if err = stmt.QueryRow().Scan(&count); err != nil {
+		t.Fatalf("unable to count the rows of %s: %v", table, err)
+	}
+	return count
+}
@@ -0,0 +1,59 @@
+package sqlitestore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxIDGenerationAttempts is how many IDs are generated for a new session before giving up, when the generated IDs
+// are already in use.
+const maxIDGenerationAttempts = 5
+
+// SetIDGenerator sets the function generating the IDs of the new sessions, e.g. to use ULIDs. The generated IDs must
+// be collision resistant: when one is already in use, another one is generated, giving up after 5 attempts.
+// By default, or when generator is nil, the sessions are identified by the integer ID which SQLite assigns to the row,
+// or by a random ID if the ID column is a TEXT one (see Schema.TextIDs).
+//
+// The id column of the table created by the store is an INTEGER PRIMARY KEY unless Schema.TextIDs is set, and it only
+// accepts integer IDs: generating any other kind of ID requires Schema.TextIDs, or a table created by the caller with
+// e.g. a TEXT PRIMARY KEY id column (see Schema.SkipTableCreation).
+func (m *SqliteStore) SetIDGenerator(generator func() string) {
+	if generator == nil && m.schema.TextIDs {
+		generator = randomID
+	}
+	m.idGenerator = generator
+}
+
+// randomID returns 128 random bits encoded in lowercase base32, the default IDs of the sessions of a table with a TEXT
+// ID column.
+func randomID() string {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		//the IDs can't be generated safely without a source of randomness
+		panic("sqlitestore: unable to generate a random session ID: " + err.Error())
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(id))
+}
+
+// insertWithGeneratedID inserts a new session with an ID returned by the ID generator and returns the ID.
+func (m *SqliteStore) insertWithGeneratedID(ctx context.Context, encoded []byte, createdOn, modifiedOn, expiresOn time.Time, name string, owner sql.NullString, clientIP sql.NullString, userAgent sql.NullString) (string, error) {
+	var err error
+	for attempt := 0; attempt < maxIDGenerationAttempts; attempt++ {
+		id := m.idGenerator()
+		_, err = m.execRetry(ctx, txStmt(ctx, m.stmtInsertWithID), id, m.dataArg(encoded), timestamp(createdOn), timestamp(modifiedOn), timestamp(expiresOn), name, owner, clientIP, userAgent)
+		if err == nil {
+			return id, nil
+		}
+		if !isUniqueViolation(err) {
+			return "", err
+		}
+		m.log().Warn("Generated session ID already in use, generating another one", "session_id", id, "attempt", attempt+1)
+	}
+	return "", fmt.Errorf("unable to generate an unused session ID after %d attempts: %w", maxIDGenerationAttempts, err)
+}
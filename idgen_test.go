@@ -0,0 +1,76 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGeneratedIDsAreStoredInATextIDColumn(t *testing.T) {
+	store, _ := newTestStore(t, WithSchema(Schema{TextIDs: true}))
+	//the second session collides with the first one, and gets the next ID
+	ids := []string{"01J0000000000000000000000A", "01J0000000000000000000000A", "01J0000000000000000000000B"}
+	store.SetIDGenerator(func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	})
+
+	first := saveSession(t, store, "session", 3600, nil)
+	second := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"user": "alice"})
+	if first.ID != "01J0000000000000000000000A" || second.ID != "01J0000000000000000000000B" {
+		t.Fatalf("the sessions got the IDs %q and %q", first.ID, second.ID)
+	}
+	if len(ids) != 0 {
+		t.Errorf("%d generated IDs haven't been used, want the colliding one to be retried", len(ids))
+	}
+
+	var storedType string
+	if err := store.queryRow(context.Background(), "SELECT typeof(id) FROM sessions WHERE id = ?", second.ID).Scan(&storedType); err != nil {
+		t.Fatal(err)
+	}
+	if storedType != "text" {
+		t.Errorf("the ID is stored as %s, want text", storedType)
+	}
+	loaded, err := store.GetByID("session", second.ID)
+	if err != nil || loaded.Values["user"] != "alice" {
+		t.Errorf("loading the session returned the values %v and error %v", loaded.Values, err)
+	}
+	if count := countRows(t, store, "sessions"); count != 2 {
+		t.Errorf("%d sessions are stored, want 2", count)
+	}
+}
+
+func TestTextIDColumnGetsRandomIDsByDefault(t *testing.T) {
+	store, _ := newTestStore(t, WithSchema(Schema{TextIDs: true}))
+	first := saveSession(t, store, "session", 3600, nil)
+	second := saveSession(t, store, "session", 3600, nil)
+	if first.ID == "" || first.ID == second.ID {
+		t.Fatalf("the sessions got the IDs %q and %q", first.ID, second.ID)
+	}
+	if _, err := strconv.Atoi(first.ID); err == nil {
+		t.Errorf("the session got the integer ID %q, want a random one", first.ID)
+	}
+
+	session, err := store.GetByID("session", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["user"] = "alice"
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := store.GetByID("session", first.ID); err != nil || loaded.Values["user"] != "alice" {
+		t.Errorf("loading the updated session returned the values %v and error %v", loaded.Values, err)
+	}
+	if err = store.RenewID(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strconv.Atoi(session.ID); err == nil || session.ID == first.ID {
+		t.Errorf("the renewed session got the ID %q, want a new random one", session.ID)
+	}
+	if count := countRows(t, store, "sessions"); count != 2 {
+		t.Errorf("%d sessions are stored, want 2", count)
+	}
+}
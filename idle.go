@@ -0,0 +1,57 @@
+package sqlitestore
+
+import "time"
+
+// SetIdleTimeout sets how long the sessions can go without being accessed before they expire, independently of their
+// absolute expiry, which still applies: a session expires either when its expiry is reached, however recently it has
+// been accessed, or once it has been idle for longer than timeout, whichever comes first.
+//
+// A session is accessed when it is saved and when its last access time is recorded, with Touch or, when sliding
+// expiration is enabled (see SetSlidingExpiration), whenever its expiry is extended, so the threshold of the sliding
+// expiration should be shorter than timeout. Loading a session doesn't record its access on its own, to avoid writing
+// on every request.
+//
+// The idle sessions are deleted by the cleanups, along with the expired ones, and they can't be loaded anymore, as
+// Get, GetByID and the other loads report them as expired, while the existence checks, the counts and Touch only
+// consider the absolute expiry. The expiry grace period (see SetExpiryGracePeriod) applies to the idle timeout as
+// well. A timeout <= 0 disables it, which is the default.
+func (m *SqliteStore) SetIdleTimeout(timeout time.Duration) {
+	m.idleTimeout = max(timeout, 0)
+}
+
+// idleCutoff returns the time the sessions must have been last accessed before to be considered idle, false if no
+// idle timeout has been set.
+func (m *SqliteStore) idleCutoff() (time.Time, bool) {
+	if m.idleTimeout <= 0 {
+		return time.Time{}, false
+	}
+	return m.expiryCutoff().Add(-m.idleTimeout), true
+}
+
+// isIdle reports whether the session stored in row has been idle for longer than the idle timeout, if any. A session
+// which has never been touched was last accessed when it has been saved.
+func (m *SqliteStore) isIdle(row sessionRow) bool {
+	cutoff, ok := m.idleCutoff()
+	if !ok {
+		return false
+	}
+	lastAccess := row.modifiedOn
+	if row.lastAccess.Valid && row.lastAccess.Time.After(lastAccess) {
+		lastAccess = row.lastAccess.Time
+	}
+	return lastAccess.Before(cutoff)
+}
+
+// expiredOrIdleCondition returns the WHERE clause which matches the sessions which are expired, as
+// Schema.expiredCondition does, or idle for longer than the idle timeout, if any, along with the arguments it must be
+// bound to.
+func (m *SqliteStore) expiredOrIdleCondition() (string, []interface{}) {
+	cutoff, ok := m.idleCutoff()
+	if !ok {
+		return m.schema.expiredCondition(), []interface{}{timestamp(m.expiryCutoff())}
+	}
+	return " WHERE (julianday(" + m.schema.ExpiresOnColumn + ") < julianday(?) OR (julianday(" + m.schema.ModifiedOnColumn +
+			") < julianday(?) AND (" + m.schema.LastAccessColumn + " IS NULL OR julianday(" + m.schema.LastAccessColumn +
+			") < julianday(?))))" + m.schema.liveCondition(),
+		[]interface{}{timestamp(m.expiryCutoff()), timestamp(cutoff), timestamp(cutoff)}
+}
@@ -0,0 +1,33 @@
+package sqlitestore
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdleSessionsExpire(t *testing.T) {
+	store, clock := newTestStore(t)
+	store.SetIdleTimeout(time.Hour)
+	idle := saveSession(t, store, "session", 86400, nil)
+	touched := saveSession(t, store, "session", 86400, nil)
+
+	clock.Advance(40 * time.Minute)
+	if err := store.Touch("session", touched.ID); err != nil {
+		t.Fatalf("unable to touch the session: %v", err)
+	}
+	clock.Advance(40 * time.Minute)
+
+	if _, err := store.GetByID("session", idle.ID); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("loading an idle session returned %v, want ErrSessionExpired", err)
+	}
+	if _, err := store.GetByID("session", touched.ID); err != nil {
+		t.Errorf("loading a touched session failed: %v", err)
+	}
+	if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+		t.Errorf("cleanup deleted %d sessions with error %v, want the idle one and no error", deleted, err)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d sessions are left, want the touched one", count)
+	}
+}
@@ -0,0 +1,69 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// forEachPageSize is how many session IDs ForEachSession selects at a time.
+const forEachPageSize = 100
+
+// AllSessionIDs returns the IDs of all the sessions named sessionName, including the expired ones which haven't been
+// cleaned up yet. An empty sessionName returns the IDs of the sessions of every name.
+func (m *SqliteStore) AllSessionIDs(sessionName string) ([]string, error) {
+	if m.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	ids, _, err := m.selectSessionsIdsAndNames(context.Background(), sessionName,
+		"SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+" FROM "+m.table+" WHERE 1"+m.schema.liveCondition()+nameCond+" ORDER BY "+m.schema.IDColumn,
+		nameArgs...)
+	return ids, err
+}
+
+// ForEachSession loads the sessions named sessionName one at a time and calls fn for each one of them, including the
+// expired ones which haven't been cleaned up yet. An empty sessionName iterates over the sessions of every name.
+// The sessions are selected a page at a time, so they are never all held in memory, and no query is in progress while
+// fn runs, so fn can use the store. The iteration stops at the first error returned by fn, which is returned.
+func (m *SqliteStore) ForEachSession(sessionName string, fn func(*sessions.Session) error) error {
+	ctx := context.Background()
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	query := "SELECT " + m.schema.IDColumn + ", " + m.schema.NameColumn + " FROM " + m.table +
+		" WHERE (? IS NULL OR " + m.schema.IDColumn + " > ?)" + m.schema.liveCondition() + nameCond + " ORDER BY " + m.schema.IDColumn + " LIMIT ?"
+
+	var after interface{}
+	for {
+		if m.closed.Load() {
+			return ErrStoreClosed
+		}
+		args := append(append([]interface{}{after, after}, nameArgs...), forEachPageSize)
+		ids, names, err := m.selectSessionsIdsAndNames(ctx, sessionName, query, args...)
+		if err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			session := m.newStoredSession(id, names[i])
+			if err = m.load(ctx, session, true); err != nil {
+				if errors.Is(err, ErrSessionNotFound) {
+					//deleted in the meantime
+					continue
+				}
+				return fmt.Errorf("unable to load the session %s: %w", id, err)
+			}
+			session.IsNew = false
+			if err = fn(session); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < forEachPageSize {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
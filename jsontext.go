@@ -0,0 +1,102 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrJSONTextConflict is returned when the values are to be stored as JSON text (see SetJSONText) while they are to be
+// compressed or encrypted, as the compressed and encrypted data isn't JSON anymore.
+var ErrJSONTextConflict = errors.New("Session values can't be stored as JSON text when they are compressed or encrypted")
+
+// SetJSONText sets whether the session values are stored as bare JSON text, serialized with JSONSerializer which
+// becomes the serializer of the store, rather than in the envelopes of the store, so that they can be queried with the
+// JSON functions of SQLite, e.g. json_extract(session_data, '$.user_id'), by anyone who can read the database, as well
+// as with QueryByJSONPath. It returns ErrJSONTextConflict if compression or encryption is enabled, Save returning it
+// as well if either of them is enabled afterwards. Disabled by default.
+//
+// The values are stored in the clear and uncompressed, so the database gets larger, and the queries over them parse
+// the JSON of every session they examine, scanning the whole table unless an index is created on the expressions they
+// filter on, e.g. CREATE INDEX ... ON sessions (json_extract(session_data, '$.user_id')). The JSON functions must have
+// been compiled into SQLite: the versions of go-sqlite3 before 1.14 only include them when built with the sqlite_json
+// tag.
+//
+// The sessions already stored are still decoded, while MigrateValues rewrites them as JSON text. The JSON text has no
+// format envelope (see RegisterSerializer), so it is decoded with the current serializer only: once disabled, the
+// sessions must be rewritten with MigrateValues before the serializer is changed.
+func (m *SqliteStore) SetJSONText(enabled bool) error {
+	if !enabled {
+		m.jsonText = false
+		return nil
+	}
+	if m.compression || m.aead != nil {
+		return ErrJSONTextConflict
+	}
+	m.serializer = JSONSerializer{}
+	m.jsonText = true
+	return nil
+}
+
+// encodeJSONText serializes the session values into JSON text, which is stored without envelopes.
+func (m *SqliteStore) encodeJSONText(session *sessions.Session) ([]byte, error) {
+	if m.compression || m.aead != nil {
+		return nil, ErrJSONTextConflict
+	}
+	data, err := JSONSerializer{}.Serialize(session.Values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSerialization, err)
+	}
+	if m.valueSizeObserver != nil {
+		m.valueSizeObserver(session.ID, len(data))
+	}
+	if m.maxValueSize > 0 && len(data) > m.maxValueSize {
+		return nil, fmt.Errorf("%w: session %q is %d bytes, the maximum is %d bytes", ErrValueTooLarge, session.Name(), len(data), m.maxValueSize)
+	}
+	return data, nil
+}
+
+// dataArg returns the encoded values to bind to the data column: the JSON text is bound as a string, so that it is
+// stored as TEXT, which the JSON functions of SQLite expect, rather than as a BLOB.
+func (m *SqliteStore) dataArg(encoded []byte) interface{} {
+	if m.jsonText {
+		return string(encoded)
+	}
+	return encoded
+}
+
+// QueryByJSONPath returns the IDs of the sessions whose values, stored as JSON text (see SetJSONText), have value at
+// path, a JSON path as taken by json_extract, e.g. "$.user_id". The value is compared as text with the one at path, so
+// the numbers must be given as they are formatted by SQLite, e.g. "42", and the booleans as "1" and "0". The sessions
+// of every name are matched, including the expired ones which haven't been cleaned up yet, while the ones which
+// haven't been stored as JSON text are skipped.
+func (m *SqliteStore) QueryByJSONPath(path string, value string) ([]string, error) {
+	if m.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	ctx := context.Background()
+
+	stmt, err := m.readStmt(ctx, "SELECT "+m.schema.IDColumn+" FROM "+m.table+" WHERE typeof("+m.schema.DataColumn+") = 'text'"+
+		" AND json_valid("+m.schema.DataColumn+") AND CAST(json_extract("+m.schema.DataColumn+", ?) AS TEXT) = ?"+
+		m.schema.liveCondition()+" ORDER BY "+m.schema.IDColumn)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, path, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,87 @@
+package sqlitestore
+
+import (
+	"github.com/gorilla/securecookie"
+)
+
+// RotateKeys makes the store sign and encrypt the cookies with newPairs, pairs of hash and block keys as taken by
+// securecookie.CodecsFromPairs, whose last block key can be omitted, while keeping the previous keys to verify the
+// cookies issued before the rotation. The codecs are replaced at once, so the requests being served while the keys are
+// rotated are decoded either with the old codecs or with the new ones, and never fail because of the rotation.
+//
+// The recommended rotation procedure is:
+//  1. call RotateKeys with the new key pair: the cookies written from then on use it, the existing ones still verify;
+//  2. wait for the existing cookies to be replaced or to expire, which takes at most the MaxAge of the sessions;
+//  3. call SetKeyPairs with the new key pair only, to stop accepting the old one.
+//
+// When no serializer has been set, the session values are encoded with the codecs as well, so the values stored before
+// the rotation can't be read anymore once the old key has been removed.
+func (m *SqliteStore) RotateKeys(newPairs ...[]byte) {
+	m.codecsMu.Lock()
+	defer m.codecsMu.Unlock()
+	keyPairs := append([][]byte{}, newPairs...)
+	if len(keyPairs)%2 != 0 {
+		//the last hash key has no block key, which must be given explicitly to keep the old keys paired
+		keyPairs = append(keyPairs, nil)
+	}
+	keyPairs = append(keyPairs, m.keyPairs...)
+	m.keyPairs = keyPairs
+	m.Codecs = m.codecsFromPairs(keyPairs)
+}
+
+// SetKeyPairs replaces all the keys of the store with keyPairs, e.g. to remove the old keys after RotateKeys, see
+// RotateKeys.
+func (m *SqliteStore) SetKeyPairs(keyPairs ...[]byte) {
+	m.codecsMu.Lock()
+	defer m.codecsMu.Unlock()
+	m.keyPairs = append([][]byte{}, keyPairs...)
+	m.Codecs = m.codecsFromPairs(keyPairs)
+}
+
+// codecs returns the current codecs of the store, the first of which is used to encode.
+func (m *SqliteStore) codecs() []securecookie.Codec {
+	m.codecsMu.RLock()
+	defer m.codecsMu.RUnlock()
+	return m.Codecs
+}
+
+// valueCodecs returns the codecs used to encode the session values when no serializer has been set: copies of the
+// current codecs without the 4096 bytes limit securecookie puts on the length of the cookies, which the values stored
+// in the database don't have to fit in.
+func (m *SqliteStore) valueCodecs() []securecookie.Codec {
+	codecs := m.codecs()
+	valueCodecs := make([]securecookie.Codec, len(codecs))
+	for i, codec := range codecs {
+		if secureCookie, ok := codec.(*securecookie.SecureCookie); ok {
+			unlimited := *secureCookie
+			codec = unlimited.MaxLength(0)
+		}
+		valueCodecs[i] = codec
+	}
+	return valueCodecs
+}
+
+// MaxAge sets the MaxAge of the sessions, like the MaxAge method of the gorilla stores: it sets Options.MaxAge, which
+// the sessions created from then on get, and the maximum age of the cookies accepted by the codecs, 0 making them
+// accept the cookies regardless of their age. The codecs created afterwards by RotateKeys and SetKeyPairs get the same
+// maximum age.
+func (m *SqliteStore) MaxAge(age int) {
+	m.Options.MaxAge = age
+	m.codecsMu.Lock()
+	defer m.codecsMu.Unlock()
+	m.codecsMaxAge = &age
+	m.Codecs = m.codecsFromPairs(m.keyPairs)
+}
+
+// codecsFromPairs creates the codecs for keyPairs, with the maximum age set with MaxAge, if any. codecsMu must be held.
+func (m *SqliteStore) codecsFromPairs(keyPairs [][]byte) []securecookie.Codec {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	if m.codecsMaxAge != nil {
+		for _, codec := range codecs {
+			if secureCookie, ok := codec.(*securecookie.SecureCookie); ok {
+				secureCookie.MaxAge(*m.codecsMaxAge)
+			}
+		}
+	}
+	return codecs
+}
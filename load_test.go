@@ -0,0 +1,86 @@
+package sqlitestore
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetByIDErrors(t *testing.T) {
+	store, clock := newTestStore(t)
+	expired := saveSession(t, store, "session", 60, nil)
+	corrupt := saveSession(t, store, "session", 3600, nil)
+	if _, err := store.db.Exec("UPDATE sessions SET session_data = 'garbage' WHERE id = ?", corrupt.ID); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	if _, err := store.GetByID("session", "12345"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("loading a missing session returned %v, want ErrSessionNotFound", err)
+	}
+	if _, err := store.GetByID("session", expired.ID); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("loading an expired session returned %v, want ErrSessionExpired", err)
+	}
+	if _, err := store.GetByIDEvenIfExpired("session", expired.ID); err != nil {
+		t.Errorf("loading an expired session even if expired failed: %v", err)
+	}
+	if _, err := store.GetByID("session", corrupt.ID); !errors.Is(err, ErrSessionCorrupt) || errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("loading a corrupt session returned %v, want ErrSessionCorrupt", err)
+	}
+}
+
+func TestNewStartsANewSessionForAMissingOne(t *testing.T) {
+	store, _ := newTestStore(t)
+	w := httptest.NewRecorder()
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteSession(session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := store.New(newRequest(w), "session")
+	if err != nil {
+		t.Fatalf("New returned an error for the cookie of a deleted session: %v", err)
+	}
+	if !renewed.IsNew {
+		t.Error("New didn't start a new session for the cookie of a deleted session")
+	}
+	if err = store.Save(newRequest(w), httptest.NewRecorder(), renewed); err != nil {
+		t.Fatalf("unable to save the new session: %v", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d sessions are stored, want the new one", count)
+	}
+}
+
+func TestNewSurfacesTheDatabaseErrors(t *testing.T) {
+	store, _ := newTestStore(t)
+	w := httptest.NewRecorder()
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.db.Exec("DROP TABLE sessions"); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err = store.New(newRequest(w), "session")
+	if err == nil || errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("New returned %v for a database failure, want the error of the database", err)
+	}
+	if session == nil || !session.IsNew {
+		t.Error("New didn't return a new session along with the error")
+	}
+	if _, err = store.GetByID("session", "1"); err == nil || errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetByID returned %v for a database failure, want the error of the database", err)
+	}
+}
@@ -0,0 +1,30 @@
+package sqlitestore
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a slog.Handler that drops every record, used when no logger has been set.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// SetLogger sets the logger used by the store, e.g. to report the errors encountered by the background cleanup.
+// It should be called before StartCleanup. By default nothing is logged.
+func (m *SqliteStore) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// log returns the logger set with SetLogger, or a logger that discards everything if none has been set.
+func (m *SqliteStore) log() *slog.Logger {
+	if m.logger == nil {
+		return discardLogger
+	}
+	return m.logger
+}
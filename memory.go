@@ -0,0 +1,40 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// inMemoryDatabases counts the in-memory databases opened by NewInMemory, to give each of them its own name.
+var inMemoryDatabases atomic.Int64
+
+// NewInMemory creates a store which keeps the sessions in a new in-memory database, in the "sessions" table, e.g. for
+// the tests. The database is opened in shared cache mode, so every connection of the pool, such as the ones of the
+// cleanup and of the requests being served concurrently, sees the same data, while every call opens a distinct
+// database, so that the stores created by different tests are isolated from each other.
+//
+// The data only lives as long as a connection to the database is open: the store keeps its connections open until it
+// is closed, which drops the data. For this reason WithSharedDB has no use here, as the database handle is not
+// returned. In shared cache mode the concurrent writes contend for the table locks, which fail with SQLITE_LOCKED and
+// are retried as set with SetBusyRetry, unless the accesses are serialized with WithMaxOpenConns(1).
+func NewInMemory(opts ...Option) (*SqliteStore, error) {
+	dsn := fmt.Sprintf("file:sqlitestore_memory_%d?mode=memory&cache=shared", inMemoryDatabases.Add(1))
+	db, err := sql.Open(DefaultDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	//the connections are never closed for being idle for too long, and at least one of them remains open
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+
+	store, err := New(db, "sessions", append(opts, func(o *options) error {
+		o.sharedDB = false
+		return nil
+	})...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
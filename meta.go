@@ -0,0 +1,111 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SessionMetadata describes a stored session, without its values.
+type SessionMetadata struct {
+	ID   string
+	Name string
+	// Owner is the owner of the session (see SetOwnerKey), empty if it has none.
+	Owner      string
+	CreatedOn  time.Time
+	ModifiedOn time.Time
+	ExpiresOn  time.Time
+	// LastAccess is when the session has last been touched (see Touch), the zero time if it never has.
+	LastAccess time.Time
+	// ClientIP and UserAgent describe the client the session has been created from (see SetRecordClient), they are
+	// empty if they haven't been recorded.
+	ClientIP  string
+	UserAgent string
+}
+
+// SessionMeta returns the metadata of the session with the given ID, e.g. to show when the sessions have been created
+// and last modified, without loading their values. The metadata of the expired sessions is returned as well, until
+// they are deleted; ErrSessionNotFound is returned if there is no session with the given ID.
+//
+// The timestamps are also available as the created_on, modified_on and expires_on values of the loaded sessions.
+func (m *SqliteStore) SessionMeta(id string) (SessionMetadata, error) {
+	if m.closed.Load() {
+		return SessionMetadata{}, ErrStoreClosed
+	}
+	ctx := context.Background()
+
+	stmt, err := m.readStmt(ctx, "SELECT "+m.schema.NameColumn+", "+m.schema.OwnerColumn+", "+m.schema.CreatedOnColumn+", "+
+		m.schema.ModifiedOnColumn+", "+m.schema.ExpiresOnColumn+", "+m.schema.LastAccessColumn+", "+
+		m.schema.ClientIPColumn+", "+m.schema.UserAgentColumn+" FROM "+m.table+
+		" WHERE "+m.schema.IDColumn+" = ?"+m.schema.liveCondition())
+	if err != nil {
+		return SessionMetadata{}, err
+	}
+
+	meta := SessionMetadata{ID: id}
+	var name, owner, clientIP, userAgent sql.NullString
+	var lastAccess sql.NullTime
+	err = stmt.QueryRowContext(ctx, id).Scan(&name, &owner, &meta.CreatedOn, &meta.ModifiedOn, &meta.ExpiresOn, &lastAccess, &clientIP, &userAgent)
+	if err == sql.ErrNoRows {
+		return SessionMetadata{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return SessionMetadata{}, err
+	}
+	meta.Name = name.String
+	meta.Owner = owner.String
+	meta.LastAccess = lastAccess.Time
+	meta.ClientIP = clientIP.String
+	meta.UserAgent = userAgent.String
+	return meta, nil
+}
+
+// SessionSize is the size of the stored data of a session, see LargestSessions.
+type SessionSize struct {
+	ID   string
+	Name string
+	// Size is the number of bytes stored for the values of the session, as they have been serialized, compressed and
+	// encrypted, depending on how the store has been configured when they have been saved.
+	Size int
+}
+
+// LargestSessions returns the n sessions named sessionName whose stored data is the largest, the largest first, e.g.
+// to find the sessions which bloat the database without loading them. The expired sessions are included until they
+// are deleted. An empty sessionName returns the largest sessions of every name.
+func (m *SqliteStore) LargestSessions(sessionName string, n int) ([]SessionSize, error) {
+	if m.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	ctx := context.Background()
+
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	stmt, err := m.readStmt(ctx, "SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+", COALESCE(length(CAST("+m.schema.DataColumn+" AS BLOB)), 0) FROM "+
+		m.table+" WHERE 1"+m.schema.liveCondition()+nameCond+" ORDER BY 3 DESC, "+m.schema.IDColumn+" LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, append(nameArgs, n)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sizes []SessionSize
+	for rows.Next() {
+		var size SessionSize
+		var name sql.NullString
+		if err = rows.Scan(&size.ID, &name, &size.Size); err != nil {
+			return nil, err
+		}
+		size.Name = name.String
+		if size.Name == "" {
+			//stored before the session name was
+			size.Name = sessionName
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, rows.Err()
+}
@@ -0,0 +1,231 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+// schemaVersionsTable records the version of the schema of each sessions table of the database, which is the number
+// of migrations applied to it.
+const schemaVersionsTable = "`sqlitestore_schema_versions`"
+
+// migration is a step which upgrades a sessions table to the next schema version.
+// Every step must be idempotent, as the tables created by the versions of the store which didn't record their schema
+// version may have already been upgraded by some of them.
+type migration struct {
+	description string
+	apply       func(db DB, schema Schema) error
+}
+
+// migrations are the steps which upgrade a sessions table from each schema version to the next one, in order.
+// New steps must only be appended.
+var migrations = []migration{
+	{"create the sessions table", func(db DB, schema Schema) error {
+		idType := "INTEGER PRIMARY KEY"
+		if schema.TextIDs {
+			//unlike an INTEGER PRIMARY KEY, a TEXT one accepts NULL unless the column is declared NOT NULL
+			idType = "TEXT PRIMARY KEY NOT NULL"
+		}
+		_, err := db.Exec("CREATE TABLE IF NOT EXISTS " +
+			schema.Table + " (" + schema.IDColumn + " " + idType + ", " +
+			schema.DataColumn + " LONGBLOB, " +
+			schema.CreatedOnColumn + " TIMESTAMP DEFAULT 0, " +
+			schema.ModifiedOnColumn + " TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
+			schema.ExpiresOnColumn + " TIMESTAMP DEFAULT 0);")
+		return err
+	}},
+	{"add the session name column", func(db DB, schema Schema) error {
+		return addColumnIfMissing(db, schema, schema.NameColumn, "TEXT")
+	}},
+	{"add the owner column and its index", func(db DB, schema Schema) error {
+		if err := addColumnIfMissing(db, schema, schema.OwnerColumn, "TEXT"); err != nil {
+			return err
+		}
+		return createIndex(db, schema, schema.OwnerColumn, schema.OwnerColumn)
+	}},
+	{"add the index on the expiry", func(db DB, schema Schema) error {
+		//the index is on the expression the cleanup compares, so that it doesn't scan the whole table
+		return createIndex(db, schema, schema.ExpiresOnColumn, "julianday("+schema.ExpiresOnColumn+")")
+	}},
+	{"add the soft deletion column", func(db DB, schema Schema) error {
+		return addColumnIfMissing(db, schema, schema.DeletedAtColumn, "TIMESTAMP")
+	}},
+	{"add the last access column", func(db DB, schema Schema) error {
+		return addColumnIfMissing(db, schema, schema.LastAccessColumn, "TIMESTAMP")
+	}},
+	{"add the client IP and user agent columns", func(db DB, schema Schema) error {
+		if err := addColumnIfMissing(db, schema, schema.ClientIPColumn, "TEXT"); err != nil {
+			return err
+		}
+		return addColumnIfMissing(db, schema, schema.UserAgentColumn, "TEXT")
+	}},
+}
+
+// Migrate creates the sessions table described by schema, or upgrades it to the current schema version if it has been
+// created by an older version of the store, logging each applied step to logger (which may be nil).
+// The schema version of each table is recorded in the sqlitestore_schema_versions table, so the steps which have
+// already been applied are skipped. The store constructors call Migrate themselves, unless schema.SkipTableCreation
+// is set, but they can't log the applied steps, so Migrate can be called before them to log the upgrade.
+func Migrate(db DB, schema Schema, logger *slog.Logger) error {
+	schema, err := schema.normalize()
+	if err != nil {
+		return err
+	}
+	if logger == nil {
+		logger = discardLogger
+	}
+	return migrate(db, schema, logger)
+}
+
+// migrate is Migrate for an already normalized schema. When the table doesn't exist yet and the schema has extra DDL
+// statements, the table is created and the statements are run in a transaction, if the DB can begin one.
+func migrate(db DB, schema Schema, logger *slog.Logger) error {
+	if len(schema.ExtraDDL) == 0 {
+		return applyMigrations(db, schema, logger)
+	}
+	exists, err := tableExists(db, schema)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return applyMigrations(db, schema, logger)
+	}
+
+	create := func(db DB) error {
+		if err := applyMigrations(db, schema, logger); err != nil {
+			return err
+		}
+		for _, statement := range schema.ExtraDDL {
+			if _, err := db.Exec(statement); err != nil {
+				logger.Error("Unable to run the extra DDL statement", "table", schema.unquotedTable(), "statement", statement, "error", err)
+				return err
+			}
+			logger.Info("Ran the extra DDL statement", "table", schema.unquotedTable(), "statement", statement)
+		}
+		return nil
+	}
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return create(db)
+	}
+	tx, err := beginner.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err = create(txDB{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// txDB is a transaction used as a DB. Its Close does nothing, as the transaction is ended by whoever began it.
+type txDB struct {
+	*sql.Tx
+}
+
+func (txDB) Close() error {
+	return nil
+}
+
+// applyMigrations applies the migrations the table is missing.
+func applyMigrations(db DB, schema Schema, logger *slog.Logger) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + schemaVersionsTable +
+		" (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL);"); err != nil {
+		return err
+	}
+
+	version, err := schemaVersion(db, schema)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		logger.Warn("The sessions table has been upgraded by a newer version of the store",
+			"table", schema.unquotedTable(), "version", version, "supported_version", len(migrations))
+		return nil
+	}
+
+	for ; version < len(migrations); version++ {
+		step := migrations[version]
+		if err = step.apply(db, schema); err != nil {
+			logger.Error("Unable to upgrade the sessions table", "table", schema.unquotedTable(), "version", version+1, "step", step.description, "error", err)
+			return err
+		}
+		if err = setSchemaVersion(db, schema, version+1); err != nil {
+			return err
+		}
+		logger.Info("Upgraded the sessions table", "table", schema.unquotedTable(), "version", version+1, "step", step.description)
+	}
+	return nil
+}
+
+// schemaVersion returns the recorded schema version of the table, which is 0 if none has been recorded.
+func schemaVersion(db DB, schema Schema) (int, error) {
+	stmt, err := db.Prepare("SELECT version FROM " + schemaVersionsTable + " WHERE table_name = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var version int
+	err = stmt.QueryRow(schema.unquotedTable()).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// setSchemaVersion records the schema version of the table.
+func setSchemaVersion(db DB, schema Schema, version int) error {
+	stmt, err := db.Prepare("INSERT INTO " + schemaVersionsTable + " (table_name, version) VALUES (?, ?)" +
+		" ON CONFLICT (table_name) DO UPDATE SET version = excluded.version")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(schema.unquotedTable(), version)
+	return err
+}
+
+// tableExists reports whether the table of the schema exists.
+func tableExists(db DB, schema Schema) (bool, error) {
+	stmt, err := db.Prepare("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var count int
+	if err = stmt.QueryRow(schema.unquotedTable()).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// addColumnIfMissing adds the column to the table of the schema, unless the table already has it.
+func addColumnIfMissing(db DB, schema Schema, column string, definition string) error {
+	stmt, err := db.Prepare("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ? COLLATE NOCASE")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var count int
+	if err = stmt.QueryRow(schema.unquotedTable(), unquote(column)).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = db.Exec("ALTER TABLE " + schema.Table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// createIndex creates the index named after column on expression, unless it already exists.
+func createIndex(db DB, schema Schema, column string, expression string) error {
+	_, err := db.Exec("CREATE INDEX IF NOT EXISTS " + schema.indexName(column) + " ON " + schema.Table + " (" + expression + ")")
+	return err
+}
@@ -0,0 +1,154 @@
+//go:build modernc
+
+// The tests of this file run the store on the pure Go modernc.org/sqlite driver rather than on go-sqlite3:
+//
+//	go test -tags modernc .
+//
+// and, to check that the store builds and works without cgo:
+//
+//	CGO_ENABLED=0 go test -tags modernc -run Modernc .
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/maxbarbieri/sqlitestore/sqlitestoretest"
+	_ "modernc.org/sqlite"
+)
+
+// newModerncTestStore is like newTestStore, but the database file is opened with modernc.org/sqlite, with the pragmas
+// of its DSN applied to each connection.
+func newModerncTestStore(t *testing.T, dsnParams string, opts ...Option) (*SqliteStore, *sqlitestoretest.FakeClock, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	db, err := sql.Open("sqlite", "file:"+path+dsnParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	opts = append([]Option{
+		WithKeyPairs([]byte("test hash key")),
+		WithClock(clock),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	store, err := New(db, "sessions", opts...)
+	if err != nil {
+		t.Fatalf("unable to create the store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, clock, path
+}
+
+func TestModerncPragmas(t *testing.T) {
+	store, _, _ := newModerncTestStore(t, "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(1234)",
+		WithPragmas(Pragma{"user_version", "7"}))
+	ctx := context.Background()
+
+	var conns []*sql.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		conn, err := store.db.(*sql.DB).Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+		var journalMode string
+		var busyTimeout, userVersion int
+		if err = conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatal(err)
+		}
+		//user_version is persisted in the database file, so WithPragmas reaches every connection
+		if err = conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&userVersion); err != nil {
+			t.Fatal(err)
+		}
+		if journalMode != "wal" || busyTimeout != 1234 || userVersion != 7 {
+			t.Errorf("connection %d has the journal mode %s, the busy timeout %d and the user version %d, want wal, 1234 and 7",
+				i, journalMode, busyTimeout, userVersion)
+		}
+	}
+}
+
+func TestModerncSessionsExpire(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("the time zone database isn't available: %v", err)
+	}
+	for _, callbacks := range []bool{false, true} {
+		store, clock, _ := newModerncTestStore(t, "?_pragma=busy_timeout(5000)")
+		store.SetLocation(newYork)
+		if callbacks {
+			//the expired sessions are selected and loaded a page at a time, rather than deleted with one statement
+			store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {})
+		}
+		saved := saveSession(t, store, "session", 60, map[interface{}]interface{}{"user": "alice"})
+
+		loaded, err := store.GetByID("session", saved.ID)
+		if err != nil {
+			t.Fatalf("unable to load the session: %v", err)
+		}
+		//the timestamps are scanned back as instants, whatever the driver returns them as
+		if createdOn, ok := loaded.Values["created_on"].(time.Time); !ok || !createdOn.Equal(testEpoch) || loaded.Values["user"] != "alice" {
+			t.Errorf("the session has been loaded with the values %v", loaded.Values)
+		}
+		if expiresOn, ok := loaded.Values["expires_on"].(time.Time); !ok || !expiresOn.Equal(testEpoch.Add(time.Minute)) {
+			t.Errorf("the session expires on %v, want %v", loaded.Values["expires_on"], testEpoch.Add(time.Minute))
+		}
+
+		clock.Advance(30 * time.Second)
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 0 {
+			t.Errorf("the cleanup deleted %d sessions with error %v before their expiry", deleted, err)
+		}
+		clock.Advance(time.Minute)
+		if _, err = store.GetByID("session", saved.ID); !errors.Is(err, ErrSessionExpired) {
+			t.Errorf("loading the expired session returned %v, want ErrSessionExpired", err)
+		}
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+			t.Errorf("the cleanup deleted %d sessions with error %v after their expiry, want 1", deleted, err)
+		}
+	}
+}
+
+func TestModerncBusyDatabase(t *testing.T) {
+	store, _, path := newModerncTestStore(t, "?_pragma=busy_timeout(0)")
+	store.SetBusyRetry(2, time.Millisecond)
+	lockDB, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockDB.Close()
+	conn, err := lockDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the error codes of modernc.org/sqlite are recognized as well as the ones of go-sqlite3
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("saving while the database is locked returned %v, want ErrDatabaseBusy", err)
+	}
+}
@@ -0,0 +1,216 @@
+package sqlitestore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gorilla/sessions"
+)
+
+// Option configures the store created by New.
+type Option func(*options) error
+
+// options is the configuration collected from the Options passed to New.
+type options struct {
+	sessionsOptions sessions.Options
+	keyPairs        [][]byte
+	schema          Schema
+	extraDDL        []string
+	pragmas         []Pragma
+	sharedDB        bool
+	//settings of the connection pool, applied before anything is run on the database
+	poolSettings []func(pool)
+	//setters applied to the store once it has been created, in the order of the options
+	setters []func(*SqliteStore) error
+}
+
+// New creates a store which keeps the sessions in the table of db, configured by opts. Unlike the setters, which can
+// be called at any time, the options are all validated and applied before the store is returned, so a store which has
+// been returned is fully configured, and an error is returned if any option is invalid.
+//
+// Like NewSqliteStoreFromConnection, New takes ownership of db, which gets closed by Close, unless WithSharedDB is
+// passed. The sessions get the same default options as the ones of NewSqliteStoreFromDB, unless WithSessionOptions is
+// passed.
+func New(db DB, tableName string, opts ...Option) (*SqliteStore, error) {
+	o := options{
+		sessionsOptions: sessions.Options{Path: "/", MaxAge: 86400 * 30},
+		schema:          DefaultSchema(tableName),
+	}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	o.schema.Table = tableName
+	if len(o.extraDDL) > 0 {
+		//copied, so that the slice of the schema passed to WithSchema isn't appended to
+		o.schema.ExtraDDL = append(append([]string{}, o.schema.ExtraDDL...), o.extraDDL...)
+	}
+
+	if len(o.poolSettings) > 0 {
+		p, ok := db.(pool)
+		if !ok {
+			return nil, errNotAPool
+		}
+		for _, set := range o.poolSettings {
+			set(p)
+		}
+	}
+
+	for _, pragma := range o.pragmas {
+		statement, err := pragma.statement()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("unable to run %q: %w", statement, err)
+		}
+	}
+
+	store, err := NewSqliteStoreWithSchema(db, o.schema, o.sessionsOptions, o.keyPairs...)
+	if err != nil {
+		return nil, err
+	}
+	store.sharedDB = o.sharedDB
+	for _, setter := range o.setters {
+		if err = setter(store); err != nil {
+			//release the statements of the store, but leave db to the caller, as no store has been returned to close it
+			store.sharedDB = true
+			store.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// WithSessionOptions sets the options of the sessions.
+func WithSessionOptions(sessionsOptions sessions.Options) Option {
+	return func(o *options) error {
+		o.sessionsOptions = sessionsOptions
+		return nil
+	}
+}
+
+// WithKeyPairs sets the key pairs of the securecookie codecs which encode the session IDs into the cookies, as taken
+// by securecookie.CodecsFromPairs.
+func WithKeyPairs(keyPairs ...[]byte) Option {
+	return func(o *options) error {
+		o.keyPairs = keyPairs
+		return nil
+	}
+}
+
+// WithSchema sets the names of the columns of the table, as NewSqliteStoreWithSchema does. The table name passed to
+// New takes precedence over the one of schema.
+func WithSchema(schema Schema) Option {
+	return func(o *options) error {
+		o.schema = schema
+		return nil
+	}
+}
+
+// WithExtraDDL adds statements to run right after the table has been created by the store, see Schema.ExtraDDL.
+func WithExtraDDL(statements ...string) Option {
+	return func(o *options) error {
+		o.extraDDL = append(o.extraDDL, statements...)
+		return nil
+	}
+}
+
+// WithSharedDB makes the store leave the database handle open on Close, as NewSqliteStoreFromDB does, for when it is
+// managed by the caller.
+func WithSharedDB() Option {
+	return func(o *options) error {
+		o.sharedDB = true
+		return nil
+	}
+}
+
+// WithPragmas runs the pragmas on db before the table is created. They are run once through db, so they only reach a
+// single connection of a pool: this is enough for the pragmas which are persisted in the database file, like
+// auto_vacuum, or for a pool limited to a single connection, while NewSqliteStoreWithPragmas applies them to every
+// connection of its pool. With go-sqlite3, the journal mode must be set through the _journal_mode DSN parameter
+// instead, as the driver sets the journal mode of each new connection itself.
+func WithPragmas(pragmas ...Pragma) Option {
+	return func(o *options) error {
+		for _, pragma := range pragmas {
+			if _, err := pragma.statement(); err != nil {
+				return err
+			}
+		}
+		o.pragmas = append(o.pragmas, pragmas...)
+		return nil
+	}
+}
+
+// WithSerializer sets the serializer of the session values, see SetSerializer.
+func WithSerializer(serializer Serializer) Option {
+	return func(o *options) error {
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			m.SetSerializer(serializer)
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the store, see SetLogger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) error {
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			m.SetLogger(logger)
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithClock sets the clock of the store, see SetClock.
+func WithClock(clock Clock) Option {
+	return func(o *options) error {
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			m.SetClock(clock)
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithEncryptionKey makes the store encrypt the session data with key, see SetEncryptionKey. Unlike SetEncryptionKey,
+// it doesn't accept an empty key, as encryption has been explicitly requested.
+func WithEncryptionKey(key []byte) Option {
+	return func(o *options) error {
+		if len(key) == 0 {
+			return errors.New("encryption requested, but the encryption key is empty")
+		}
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			return m.SetEncryptionKey(key)
+		})
+		return nil
+	}
+}
+
+// WithMaxValueSize sets the maximum size of the serialized session values, see SetMaxValueSize.
+func WithMaxValueSize(size int) Option {
+	return func(o *options) error {
+		if size < 0 {
+			return fmt.Errorf("invalid maximum value size %d", size)
+		}
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			m.SetMaxValueSize(size)
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithReadDB makes the store run its queries on readDB, see SetReadDB.
+func WithReadDB(readDB DB) Option {
+	return func(o *options) error {
+		o.setters = append(o.setters, func(m *SqliteStore) error {
+			return m.SetReadDB(readDB)
+		})
+		return nil
+	}
+}
@@ -0,0 +1,52 @@
+package sqlitestore
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestSessionsGetTheOptionsOfTheStore(t *testing.T) {
+	options := sessions.Options{Path: "/app", Domain: "example.com", MaxAge: 60, Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode}
+	store, clock := newTestStore(t, WithSessionOptions(options))
+
+	clone := cloneOptions(store.Options)
+	if !reflect.DeepEqual(*clone, *store.Options) || clone == store.Options {
+		t.Errorf("cloneOptions returned %+v, want a copy of %+v", clone, store.Options)
+	}
+
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*session.Options, options) || session.Options == store.Options {
+		t.Errorf("New returned a session with the options %+v, want a copy of %+v", session.Options, options)
+	}
+	saved := saveSession(t, store, "session", 60, nil)
+	loaded, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*loaded.Options, options) {
+		t.Errorf("GetByID returned a session with the options %+v, want %+v", loaded.Options, options)
+	}
+
+	var expired *sessions.Session
+	store.SetExpiredSessionPreDeleteCallback(func(session *sessions.Session) { expired = session })
+	clock.Advance(2 * time.Minute)
+	if _, err = store.CleanupNow(""); err != nil {
+		t.Fatal(err)
+	}
+	if expired == nil || !reflect.DeepEqual(*expired.Options, options) {
+		t.Errorf("the cleanup passed a session with the options %+v, want %+v", expired, options)
+	}
+
+	//the options of a session are its own
+	loaded.Options.Path = "/other"
+	if store.Options.Path != "/app" {
+		t.Error("changing the options of a session changed the ones of the store")
+	}
+}
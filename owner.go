@@ -0,0 +1,148 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// SetOwnerKey sets the key of the session value identifying the user who owns the session, which is stored in the
+// owner column (see Schema) every time the session is saved. The value is converted to a string with fmt.Sprint, so
+// it is typically a string or an integer user ID. The sessions which don't have the value, or whose value converts
+// to an empty string, are stored without an owner. The owner isn't stored by default.
+func (m *SqliteStore) SetOwnerKey(key interface{}) {
+	m.ownerKey = key
+}
+
+// SetMaxSessionsPerUser sets the maximum number of sessions with the same name and owner (see SetOwnerKey).
+// When a new session is saved and its owner has more sessions than that, the oldest ones are deleted, calling the
+// pre-delete and post-delete callbacks for them exactly like the cleanup does for the expired sessions.
+// A value <= 0 means that the number of sessions is unlimited, which is the default.
+func (m *SqliteStore) SetMaxSessionsPerUser(n int) {
+	m.maxSessionsPerUser = n
+}
+
+// ownerOf returns the owner to store for the session, which is NULL if it has none.
+func (m *SqliteStore) ownerOf(session *sessions.Session) sql.NullString {
+	if m.ownerKey == nil {
+		return sql.NullString{}
+	}
+	value, ok := session.Values[m.ownerKey]
+	if !ok || value == nil {
+		return sql.NullString{}
+	}
+	owner := fmt.Sprint(value)
+	return sql.NullString{String: owner, Valid: owner != ""}
+}
+
+// evictOldestSessions deletes the oldest sessions named sessionName of owner beyond the limit set with
+// SetMaxSessionsPerUser, if any.
+func (m *SqliteStore) evictOldestSessions(ctx context.Context, sessionName string, owner sql.NullString) error {
+	if m.maxSessionsPerUser <= 0 || !owner.Valid {
+		return nil
+	}
+
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	query := "SELECT " + m.schema.IDColumn + ", " + m.schema.NameColumn + " FROM " + m.table +
+		" WHERE " + m.schema.OwnerColumn + " = ?" + m.schema.liveCondition() + nameCond +
+		" ORDER BY julianday(" + m.schema.CreatedOnColumn + ") DESC, " + m.schema.IDColumn + " DESC LIMIT -1 OFFSET ?"
+	args := append(append([]interface{}{owner.String}, nameArgs...), m.maxSessionsPerUser)
+	ids, names, loaded, err := m.getSessionsIdsAndCallCallbacks(ctx, sessionName, query, args...)
+	if err != nil {
+		return fmt.Errorf("unable to select the sessions to evict: %w", err)
+	}
+	if _, err = m.deleteSessionsWithIds(ctx, ids, names, loaded, SessionDeleted, DeletionEvicted, nil); err != nil {
+		return fmt.Errorf("unable to evict the oldest sessions: %w", err)
+	}
+	if len(ids) > 0 {
+		m.log().Debug("Evicted the oldest sessions of the owner", "session_name", sessionName, "owner", owner.String, "evicted", len(ids))
+	}
+	return nil
+}
+
+// SessionsForUser returns the sessions of every name owned by userID (see SetOwnerKey) which are not expired, including
+// the ones kept in the tables of their names (see SetTableForName).
+// The sessions which are stored without an owner are never returned. When SetRecordClient is enabled, the sessions
+// have the client_ip and user_agent values of the client they have been created from.
+func (m *SqliteStore) SessionsForUser(userID string) ([]*sessions.Session, error) {
+	if m.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	userSessions, err := m.sessionsForUser(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	//the sessions of the names kept in tables of their own follow the ones of the table of the store
+	for _, shard := range m.allShards() {
+		shardSessions, err := shard.sessionsForUser(context.Background(), userID)
+		if err != nil {
+			return nil, err
+		}
+		userSessions = append(userSessions, shardSessions...)
+	}
+	return userSessions, nil
+}
+
+// sessionsForUser returns the sessions owned by userID which are not expired from the table of the store, from the
+// oldest to the newest.
+func (m *SqliteStore) sessionsForUser(ctx context.Context, userID string) ([]*sessions.Session, error) {
+	ids, names, err := m.selectSessionsIdsAndNames(ctx, "",
+		"SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+" FROM "+m.table+m.schema.activeCondition()+
+			" AND "+m.schema.OwnerColumn+" = ? ORDER BY julianday("+m.schema.CreatedOnColumn+"), "+m.schema.IDColumn,
+		timestamp(m.expiryCutoff()), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userSessions := make([]*sessions.Session, 0, len(ids))
+	for i, id := range ids {
+		session := m.newStoredSession(id, names[i])
+		if err = m.load(ctx, session, false); err != nil {
+			if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) {
+				//deleted or expired in the meantime
+				continue
+			}
+			return nil, err
+		}
+		session.IsNew = false
+		userSessions = append(userSessions, session)
+	}
+	return userSessions, nil
+}
+
+// DeleteAllByUser deletes every session of every name owned by userID (see SetOwnerKey), expired or not, and returns
+// the number of sessions actually deleted, e.g. to log the user out everywhere after a password change. The sessions
+// kept in the tables of their names (see SetTableForName) are deleted as well.
+// The pre-delete and post-delete callbacks, if set, are called for the deleted sessions exactly like the cleanup does
+// for the expired sessions.
+func (m *SqliteStore) DeleteAllByUser(userID string) (int, error) {
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	deleted, err := m.deleteAllByUser(context.Background(), userID)
+	for _, shard := range m.allShards() {
+		shardDeleted, shardErr := shard.deleteAllByUser(context.Background(), userID)
+		deleted += shardDeleted
+		err = errors.Join(err, shardErr)
+	}
+	return deleted, err
+}
+
+// deleteAllByUser deletes every session owned by userID from the table of the store.
+func (m *SqliteStore) deleteAllByUser(ctx context.Context, userID string) (int, error) {
+
+	if !m.observesDeletions() {
+		//nobody needs to see the sessions before they are gone, so delete all of them with a single statement
+		return m.execDelete(ctx, DeletionBulk, " WHERE "+m.schema.OwnerColumn+" = ?", userID)
+	}
+
+	ids, names, loaded, err := m.getSessionsIdsAndCallCallbacks(ctx, "",
+		"SELECT "+m.schema.IDColumn+", "+m.schema.NameColumn+" FROM "+m.table+" WHERE "+m.schema.OwnerColumn+" = ?"+m.schema.liveCondition(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return m.deleteSessionsWithIds(ctx, ids, names, loaded, SessionDeleted, DeletionBulk, nil)
+}
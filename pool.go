@@ -0,0 +1,57 @@
+package sqlitestore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// pool is the part of *sql.DB which configures its connection pool.
+type pool interface {
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+}
+
+// errNotAPool is returned by New when the pool options are passed with a DB which has no connection pool to configure.
+var errNotAPool = errors.New("the connection pool can only be configured on a *sql.DB")
+
+// WithMaxOpenConns sets the maximum number of open connections to the database, see sql.DB.SetMaxOpenConns. Like the
+// other pool options, it is applied before anything is run on the database, so it also bounds the connections the
+// pragmas and the creation of the table are run on. It requires db to be a *sql.DB.
+//
+// The recommended settings depend on the journal mode:
+//   - with the rollback journal (journal_mode DELETE, the default), a writer locks out the readers, so a single
+//     connection, WithMaxOpenConns(1), serializes the accesses which would otherwise fail with SQLITE_BUSY;
+//   - with WAL, the readers don't block the writer, so a few connections, e.g. WithMaxOpenConns(4) and as many idle
+//     ones, let the sessions be read concurrently, along with a busy_timeout to wait for the other writers;
+//   - with a :memory: database without shared cache, each connection opens its own empty database, so
+//     WithMaxOpenConns(1) is required, along with WithConnMaxLifetime(0) to keep the data alive.
+func WithMaxOpenConns(n int) Option {
+	return withPool(func(p pool) { p.SetMaxOpenConns(n) })
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections to the database, see sql.DB.SetMaxIdleConns and
+// WithMaxOpenConns.
+func WithMaxIdleConns(n int) Option {
+	return withPool(func(p pool) { p.SetMaxIdleConns(n) })
+}
+
+// WithConnMaxLifetime sets the maximum amount of time a connection to the database may be reused, see
+// sql.DB.SetConnMaxLifetime and WithMaxOpenConns. 0 doesn't limit it.
+func WithConnMaxLifetime(d time.Duration) Option {
+	if d < 0 {
+		return func(*options) error {
+			return fmt.Errorf("invalid connection lifetime %s", d)
+		}
+	}
+	return withPool(func(p pool) { p.SetConnMaxLifetime(d) })
+}
+
+// withPool returns the Option which configures the pool with set.
+func withPool(set func(pool)) Option {
+	return func(o *options) error {
+		o.poolSettings = append(o.poolSettings, set)
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// Pragma is a SQLite PRAGMA statement, such as Pragma{"journal_mode", "WAL"}.
+type Pragma struct {
+	Name  string
+	Value string
+}
+
+var (
+	pragmaNameRegexp  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	pragmaValueRegexp = regexp.MustCompile(`^-?[A-Za-z0-9_]+$`)
+)
+
+// statement returns the SQL statement which sets the pragma, after making sure that nothing but a pragma gets
+// interpolated into it.
+func (p Pragma) statement() (string, error) {
+	if !pragmaNameRegexp.MatchString(p.Name) {
+		return "", fmt.Errorf("invalid pragma name %q", p.Name)
+	}
+	if !pragmaValueRegexp.MatchString(p.Value) {
+		return "", fmt.Errorf("invalid value %q for pragma %s", p.Value, p.Name)
+	}
+	return "PRAGMA " + p.Name + " = " + p.Value, nil
+}
+
+// NewSqliteStoreWithPragmas is like NewSqliteStore, but it applies the pragmas to every connection to the database,
+// before any statement of the store is prepared. Most pragmas, like synchronous and busy_timeout, only affect the
+// connection they are run on, which is why they are applied to each connection of the pool as soon as it is opened.
+// The journal_mode pragma is passed to go-sqlite3 through the _journal_mode DSN parameter instead, as the driver sets
+// the journal mode of each connection itself, which is why the database is always opened with go-sqlite3: with other
+// drivers, the pragmas can be set through their DSN, or with New and WithPragmas.
+// A typical configuration for concurrent access is:
+//
+//	[]Pragma{{"journal_mode", "WAL"}, {"synchronous", "NORMAL"}, {"busy_timeout", "5000"}}
+func NewSqliteStoreWithPragmas(endpoint string, tableName string, pragmas []Pragma, sessionsOptions sessions.Options, keyPairs ...[]byte) (*SqliteStore, error) {
+	var statements []string
+	for _, pragma := range pragmas {
+		statement, err := pragma.statement()
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(pragma.Name, "journal_mode") {
+			//go-sqlite3 sets the journal mode of every new connection, to DELETE unless the DSN says otherwise,
+			//which would undo the pragma and fight with the connections already in WAL mode
+			endpoint = withDSNParam(endpoint, "_journal_mode", pragma.Value)
+			continue
+		}
+		statements = append(statements, statement)
+	}
+
+	db, err := sql.Open(DefaultDriverName, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	//reopen the database through a connector which runs the pragmas on each new connection
+	connector := pragmaConnector{driver: db.Driver(), dsn: endpoint, statements: statements}
+	db.Close()
+	db = sql.OpenDB(connector)
+
+	store, err := NewSqliteStoreFromConnection(db, tableName, sessionsOptions, keyPairs...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// withDSNParam adds the query parameter to the DSN.
+func withDSNParam(dsn string, key string, value string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+// pragmaConnector opens the connections to the database with the driver and runs the pragma statements on each.
+type pragmaConnector struct {
+	driver     driver.Driver
+	dsn        string
+	statements []string
+}
+
+func (c pragmaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range c.statements {
+		if err = execOnConn(ctx, conn, statement); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to run %q: %w", statement, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c pragmaConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// execOnConn runs the statement on a driver connection. The results of the statement, such as the ones
+// returned by PRAGMA journal_mode, are discarded, but they must still be read, as the statement only runs when stepped.
+func execOnConn(ctx context.Context, conn driver.Conn, statement string) error {
+	var rows driver.Rows
+	var err error
+	if queryer, ok := conn.(driver.QueryerContext); ok {
+		rows, err = queryer.QueryContext(ctx, statement, nil)
+	} else {
+		var stmt driver.Stmt
+		if stmt, err = conn.Prepare(statement); err != nil {
+			return err
+		}
+		defer stmt.Close()
+		rows, err = stmt.Query(nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err = rows.Next(dest); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
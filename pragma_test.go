@@ -0,0 +1,122 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/maxbarbieri/sqlitestore/sqlitestoretest"
+)
+
+// newPragmaTestStore returns a store keeping the sessions in a new database file, opened with the pragmas, with a fake
+// clock set to testEpoch. The store is closed when the test ends.
+func newPragmaTestStore(t *testing.T, pragmas ...Pragma) (*SqliteStore, *sqlitestoretest.FakeClock) {
+	t.Helper()
+	store, err := NewSqliteStoreWithPragmas("file:"+filepath.Join(t.TempDir(), "sessions.db"), "sessions", pragmas,
+		sessions.Options{Path: "/", MaxAge: 3600}, []byte("test hash key"))
+	if err != nil {
+		t.Fatalf("unable to create the store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	store.SetClock(clock)
+	return store, clock
+}
+
+func TestPragmasAreAppliedToEveryConnection(t *testing.T) {
+	store, _ := newPragmaTestStore(t, Pragma{"journal_mode", "WAL"}, Pragma{"busy_timeout", "1234"}, Pragma{"synchronous", "NORMAL"})
+	db := store.db.(*sql.DB)
+	ctx := context.Background()
+
+	//hold several connections at once, so that the pool has to open a new one for each of them
+	var conns []*sql.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+
+		var journalMode string
+		var busyTimeout, synchronous int
+		if err = conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous); err != nil {
+			t.Fatal(err)
+		}
+		//synchronous NORMAL is 1
+		if journalMode != "wal" || busyTimeout != 1234 || synchronous != 1 {
+			t.Errorf("connection %d has the journal mode %s, the busy timeout %d and synchronous %d, want wal, 1234 and 1",
+				i, journalMode, busyTimeout, synchronous)
+		}
+	}
+	if open := db.Stats().OpenConnections; open < len(conns) {
+		t.Errorf("%d connections are open, want at least %d", open, len(conns))
+	}
+}
+
+func TestConcurrentSavesDuringACleanup(t *testing.T) {
+	store, clock := newPragmaTestStore(t, Pragma{"journal_mode", "WAL"}, Pragma{"busy_timeout", "5000"})
+	for i := 0; i < 200; i++ {
+		saveSession(t, store, "session", 60, nil)
+	}
+	//the expired sessions are loaded before being deleted, holding the database for longer
+	store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {})
+	clock.Advance(2 * time.Minute)
+
+	const writers = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, writers+1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 200 {
+			errs <- fmt.Errorf("the cleanup deleted %d sessions with error %v, want 200", deleted, err)
+		}
+	}()
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				session, err := store.New(newRequest(nil), "session")
+				if err == nil {
+					session.Options.MaxAge = 3600
+					err = store.Save(newRequest(nil), httptest.NewRecorder(), session)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if strings.Contains(err.Error(), "locked") {
+			t.Errorf("a write failed on the locked database: %v", err)
+		} else {
+			t.Error(err)
+		}
+	}
+	if count := countRows(t, store, "sessions"); count != writers*25 {
+		t.Errorf("%d sessions are stored, want %d", count, writers*25)
+	}
+}
@@ -0,0 +1,74 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// SetReadDB makes the store run its queries on readDB, e.g. a pool of read-only connections to the same database in
+// WAL mode, so that loading the sessions, checking whether they exist and counting them never contend with the
+// writes, which keep being run on the DB the store has been created with. The statements of the store which only read
+// are prepared on readDB, replacing the ones prepared by a previous call; a nil readDB makes the queries run on the
+// primary DB again. SetReadDB should be called before the store is used.
+//
+// The store never closes readDB, which remains up to the caller. The queries run within the transactions of the
+// store, like the ones loading the sessions being evicted on Save, are still run on the primary DB, so that they see
+// the writes of the transaction.
+func (m *SqliteStore) SetReadDB(readDB DB) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+
+	var stmtReadSelect, stmtReadExists *sql.Stmt
+	if readDB != nil {
+		var err error
+		if stmtReadSelect, err = readDB.Prepare(m.schema.selectQuery()); err != nil {
+			return err
+		}
+		if stmtReadExists, err = readDB.Prepare(m.schema.existsQuery()); err != nil {
+			stmtReadSelect.Close()
+			return err
+		}
+	}
+
+	err := m.closeReadStatements()
+	m.readDB = readDB
+	m.stmtReadSelect = stmtReadSelect
+	m.stmtReadExists = stmtReadExists
+	return err
+}
+
+// closeReadStatements closes the statements prepared on the read DB, if any.
+func (m *SqliteStore) closeReadStatements() error {
+	if m.readDB == nil {
+		return nil
+	}
+	return errors.Join(m.stmtReadSelect.Close(), m.stmtReadExists.Close(), m.readStmts.close())
+}
+
+// selectStmt returns the statement which selects a session, prepared on the read DB if one has been set, unless ctx
+// runs a transaction, which must see its own writes.
+func (m *SqliteStore) selectStmt(ctx context.Context) *sql.Stmt {
+	if m.readDB != nil && txFrom(ctx) == nil {
+		return m.stmtReadSelect
+	}
+	return txStmt(ctx, m.stmtSelect)
+}
+
+// existsStmt returns the statement which checks whether a session exists, prepared on the read DB if one has been set.
+func (m *SqliteStore) existsStmt() *sql.Stmt {
+	if m.readDB != nil {
+		return m.stmtReadExists
+	}
+	return m.stmtExists
+}
+
+// readStmt returns the statement of the query, which must only read, like stmt does, but prepared on the read DB if one
+// has been set, unless ctx runs a transaction.
+func (m *SqliteStore) readStmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if m.readDB != nil && txFrom(ctx) == nil {
+		return m.readStmts.get(m.readDB, query)
+	}
+	return m.stmt(ctx, query)
+}
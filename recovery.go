@@ -0,0 +1,176 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrDatabaseCorrupt wraps the errors returned because the database file is corrupt (SQLITE_CORRUPT) or is not a
+	// database at all (SQLITE_NOTADB). They are not going to go away by retrying, unlike the ones wrapped by
+	// ErrDatabaseBusy.
+	ErrDatabaseCorrupt = errors.New("Database corrupt")
+	// ErrDatabaseBusy wraps the errors returned because the database is busy or locked by another connection, once the
+	// retries set with SetBusyRetry have been exhausted. They are transient, so the operation can be retried later.
+	ErrDatabaseBusy = errors.New("Database busy")
+	// ErrTableMissing wraps the errors returned because the sessions table doesn't exist, e.g. because the store has
+	// been created with Schema.SkipTableCreation before the table, or because the table has been dropped since.
+	ErrTableMissing = errors.New("Sessions table missing")
+)
+
+// SQLite result codes of the corrupt databases.
+const (
+	sqliteCorrupt = 11
+	sqliteNotADB  = 26
+)
+
+// isCorrupt reports whether err has been caused by the database file being corrupt.
+func isCorrupt(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	return ok && (code&0xff == sqliteCorrupt || code&0xff == sqliteNotADB)
+}
+
+// isTableMissing reports whether err has been caused by a table which doesn't exist. SQLite reports it with the
+// generic SQLITE_ERROR code, so it is told apart by its message.
+func isTableMissing(err error) bool {
+	return errors.Is(err, ErrTableMissing) || (err != nil && strings.Contains(err.Error(), "no such table"))
+}
+
+// IntegrityError is returned by CheckIntegrity when PRAGMA integrity_check finds problems in the database, it wraps
+// ErrDatabaseCorrupt.
+type IntegrityError struct {
+	// Problems are the problems reported by PRAGMA integrity_check, one for each row it returned.
+	Problems []string
+}
+
+func (e *IntegrityError) Error() string {
+	return "integrity check failed: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ErrDatabaseCorrupt
+}
+
+// CheckIntegrity runs PRAGMA integrity_check on the database, returning an *IntegrityError listing the problems it
+// found, if any. The check reads the whole database, so it takes a while on large databases.
+func (m *SqliteStore) CheckIntegrity(ctx context.Context) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+	stmt, err := m.db.Prepare("PRAGMA integrity_check")
+	if err != nil {
+		return classifyError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err = rows.Scan(&problem); err != nil {
+			return err
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return classifyError(err)
+	}
+	if len(problems) > 0 {
+		return &IntegrityError{Problems: problems}
+	}
+	return nil
+}
+
+// SetRecoveryMode sets whether the store tries to recover when the database turns out to be corrupt. Enabling it runs
+// the recovery straight away, so that a database left corrupt by a crash is detected when the store is opened rather
+// than by the first request, returning the outcome of the recovery. Disabled by default.
+//
+// The recovery checkpoints the WAL file, if any, with PRAGMA wal_checkpoint(TRUNCATE), so that the pages left in it
+// by a crash are written back to the database file, and then checks the integrity of the database with CheckIntegrity.
+// If the database is fine, the operation which failed can be retried; otherwise the problems found are logged and
+// returned as an *IntegrityError, along with the error of the operation. The recovery never rewrites nor deletes any
+// data: a database which fails the check must be repaired by hand, e.g. with the .recover command of the sqlite3
+// shell, or restored from a backup.
+//
+// Once enabled, the recovery runs on the errors caused by the corruption of the database, one at a time. Once it has
+// found the database corrupt it isn't run anymore, its error being returned along with the ones of the operations,
+// until SetRecoveryMode is called again. Whether it is enabled or not, the corruption errors returned by the store
+// wrap ErrDatabaseCorrupt.
+func (m *SqliteStore) SetRecoveryMode(enabled bool) error {
+	m.recoveryMu.Lock()
+	m.recoveryMode = enabled
+	m.recoveryFailed = nil
+	m.recoveryMu.Unlock()
+	if !enabled {
+		return nil
+	}
+	return m.recover(context.Background())
+}
+
+// recover runs the recovery described by SetRecoveryMode, once at a time. When the recovery fails, its error is
+// returned by the next recoveries without running them again, as the database is not going to repair itself.
+func (m *SqliteStore) recover(ctx context.Context) error {
+	m.recoveryMu.Lock()
+	defer m.recoveryMu.Unlock()
+	if m.recoveryFailed != nil {
+		return m.recoveryFailed
+	}
+
+	m.log().Warn("Checking the integrity of the database")
+	if _, err := m.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		//not in WAL mode, or the WAL can't be read: the integrity check reports what is wrong
+		m.log().Warn("Unable to checkpoint the WAL", "error", err)
+	}
+	if err := m.CheckIntegrity(ctx); err != nil {
+		m.log().Error("The database is corrupt, it must be repaired by hand or restored from a backup", "error", err)
+		if errors.Is(err, ErrDatabaseCorrupt) {
+			m.recoveryFailed = err
+		}
+		return err
+	}
+	m.log().Info("The database passed the integrity check")
+	return nil
+}
+
+// classifyError wraps err in ErrDatabaseCorrupt, ErrDatabaseBusy or ErrTableMissing if it has been caused by the
+// corruption of the database, by the database being busy or by a missing table. Any other error is returned unchanged.
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isBusy(err):
+		return fmt.Errorf("%w: %w", ErrDatabaseBusy, err)
+	case isCorrupt(err):
+		return fmt.Errorf("%w: %w", ErrDatabaseCorrupt, err)
+	case isTableMissing(err) && !errors.Is(err, ErrTableMissing):
+		return fmt.Errorf("%w: %w", ErrTableMissing, err)
+	}
+	return err
+}
+
+// handleError classifies err like classifyError does, running the recovery if it is enabled and err has been caused by
+// the corruption of the database. The recovery isn't run within a transaction, which may hold the only connection of
+// the pool.
+func (m *SqliteStore) handleError(ctx context.Context, err error) error {
+	if !isCorrupt(err) {
+		return classifyError(err)
+	}
+
+	m.recoveryMu.Lock()
+	recoveryMode := m.recoveryMode
+	m.recoveryMu.Unlock()
+	if recoveryMode && txFrom(ctx) == nil {
+		if recErr := m.recover(ctx); recErr != nil {
+			return fmt.Errorf("%w: %w (%w)", ErrDatabaseCorrupt, err, recErr)
+		}
+	}
+	return classifyError(err)
+}
@@ -0,0 +1,98 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// RenewID gives the session a new ID, e.g. to prevent session fixation after the user logs in: the stored session is
+// moved to a new row, with the same data and expiry, and the old ID stops identifying any session. The new row is
+// inserted and the old one deleted in a single transaction, so the session is never lost nor duplicated. The new ID is
+// returned by the ID generator (see SetIDGenerator), or assigned by SQLite if no generator has been set.
+//
+// RenewID only changes session.ID: the session must be saved afterwards, to send the cookie with the new ID, which
+// also stores the values changed since the session has been loaded. A session which has never been saved has no ID
+// to renew, and is left untouched. ErrSessionNotFound is returned if the session isn't stored anymore, or if it is
+// expired, and ErrTransactionsUnsupported if the DB of the store can't begin transactions.
+func (m *SqliteStore) RenewID(session *sessions.Session) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+	if session.ID == "" {
+		return nil
+	}
+	ctx := context.Background()
+
+	columns := m.schema.DataColumn + ", " + m.schema.CreatedOnColumn + ", " + m.schema.ModifiedOnColumn + ", " +
+		m.schema.ExpiresOnColumn + ", " + m.schema.NameColumn + ", " + m.schema.OwnerColumn + ", " + m.schema.LastAccessColumn + ", " +
+		m.schema.ClientIPColumn + ", " + m.schema.UserAgentColumn
+	copyQ := "INSERT INTO " + m.table + " (" + m.schema.IDColumn + ", " + columns + ") SELECT ?, " + columns +
+		" FROM " + m.table + m.schema.activeCondition() + " AND " + m.schema.IDColumn + " = ?"
+	deleteQ := "DELETE FROM " + m.table + " WHERE " + m.schema.IDColumn + " = ?"
+	deleteArgs := []interface{}{session.ID}
+	if m.softDelete {
+		deleteQ = "UPDATE " + m.table + " SET " + m.schema.DeletedAtColumn + " = ? WHERE " + m.schema.IDColumn + " = ?"
+		deleteArgs = []interface{}{timestamp(m.now()), session.ID}
+	}
+
+	var newID string
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		copyStmt, err := tx.PrepareContext(ctx, copyQ)
+		if err != nil {
+			return err
+		}
+		defer copyStmt.Close()
+
+		var res sql.Result
+		for attempt := 0; ; attempt++ {
+			//a NULL ID makes SQLite assign the ID of the row
+			var id interface{}
+			if m.idGenerator != nil {
+				id = m.idGenerator()
+			}
+			res, err = m.execRetry(ctx, copyStmt, id, timestamp(m.expiryCutoff()), session.ID)
+			if err == nil {
+				if id != nil {
+					newID = id.(string)
+				}
+				break
+			}
+			if !isUniqueViolation(err) || m.idGenerator == nil {
+				return err
+			}
+			if attempt+1 >= maxIDGenerationAttempts {
+				return fmt.Errorf("unable to generate an unused session ID after %d attempts: %w", maxIDGenerationAttempts, err)
+			}
+			m.log().Warn("Generated session ID already in use, generating another one", "session_id", id, "attempt", attempt+1)
+		}
+
+		if copied, err := res.RowsAffected(); err != nil {
+			return err
+		} else if copied == 0 {
+			return ErrSessionNotFound
+		}
+		if newID == "" {
+			lastInserted, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			newID = fmt.Sprintf("%d", lastInserted)
+		}
+
+		_, err = tx.ExecContext(ctx, deleteQ, deleteArgs...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	m.log().Debug("Session ID renewed", "session_name", session.Name(), "old_session_id", session.ID, "session_id", newID)
+	m.invalidateCached(ctx, session.ID)
+	m.emit(ctx, SessionDeleted, session.ID, session.Name())
+	m.emit(ctx, SessionCreated, newID, session.Name())
+	session.ID = newID
+	return nil
+}
@@ -0,0 +1,71 @@
+package sqlitestore
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRenewID(t *testing.T) {
+	store, _ := newTestStore(t)
+	saved := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"user": "alice"})
+	session, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := session.ID
+
+	if err = store.RenewID(session); err != nil {
+		t.Fatalf("unable to renew the ID: %v", err)
+	}
+	if session.ID == oldID {
+		t.Fatal("the session has kept its ID")
+	}
+	if _, err = store.GetByID("session", oldID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("loading the old ID returned %v, want ErrSessionNotFound", err)
+	}
+	renewed, err := store.GetByID("session", session.ID)
+	if err != nil {
+		t.Fatalf("unable to load the new ID: %v", err)
+	}
+	if renewed.Values["user"] != "alice" {
+		t.Errorf("the session has the values %v after the renewal, want the ones it had", renewed.Values)
+	}
+
+	w := httptest.NewRecorder()
+	session.Values["user"] = "bob"
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatalf("unable to save the renewed session: %v", err)
+	}
+	if loaded, err := store.New(newRequest(w), "session"); err != nil || loaded.ID != session.ID || loaded.Values["user"] != "bob" {
+		t.Errorf("the cookie of the renewed session loads %v with the values %v and error %v", loaded.ID, loaded.Values, err)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d sessions are stored, want 1", count)
+	}
+}
+
+func TestRenewIDOfMissingSessions(t *testing.T) {
+	store, clock := newTestStore(t)
+	unsaved, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.RenewID(unsaved); err != nil || unsaved.ID != "" {
+		t.Errorf("renewing a session never saved returned %v and the ID %q, want no error and no ID", err, unsaved.ID)
+	}
+
+	expired := saveSession(t, store, "session", 60, nil)
+	clock.Advance(2 * time.Minute)
+	if err = store.RenewID(expired); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("renewing an expired session returned %v, want ErrSessionNotFound", err)
+	}
+	deleted := saveSession(t, store, "session", 3600, nil)
+	if _, err = store.DeleteSession(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.RenewID(deleted); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("renewing a deleted session returned %v, want ErrSessionNotFound", err)
+	}
+}
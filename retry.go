@@ -0,0 +1,55 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	defaultBusyRetries = 3
+	defaultBusyBackoff = 10 * time.Millisecond
+)
+
+// SetBusyRetry sets how many times the writes to the database are retried when they fail because the database is
+// busy or locked (SQLITE_BUSY or SQLITE_LOCKED), and how long to wait before the first retry, the wait doubling after
+// each retry. Any other error is returned straight away. By default the writes are retried 3 times, starting after
+// 10ms; retries <= 0 disables the retries. Once the retries have been exhausted, the error wraps ErrDatabaseBusy.
+func (m *SqliteStore) SetBusyRetry(retries int, backoff time.Duration) {
+	m.busyRetries = retries
+	m.busyBackoff = backoff
+}
+
+// isBusy reports whether err has been caused by the database being busy or locked.
+func isBusy(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	//the primary result code is the least significant byte of the extended one
+	return ok && (code&0xff == sqliteBusy || code&0xff == sqliteLocked)
+}
+
+// isUniqueViolation reports whether err has been caused by a row violating a primary key or unique constraint.
+func isUniqueViolation(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	return ok && (code == sqliteConstraintPrimaryKey || code == sqliteConstraintUnique)
+}
+
+// execRetry executes the prepared statement, retrying it while it fails because the database is busy or locked.
+func (m *SqliteStore) execRetry(ctx context.Context, stmt *sql.Stmt, args ...interface{}) (sql.Result, error) {
+	backoff := m.busyBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := stmt.ExecContext(ctx, args...)
+		if err == nil || attempt >= m.busyRetries || !isBusy(err) {
+			return res, m.handleError(ctx, err)
+		}
+
+		m.log().Debug("Database busy, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, classifyError(err)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,76 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// lockedStore returns a store on a database file and a connection to it holding the write lock, which the store
+// doesn't wait for as its connections have no busy timeout, unlike the default one of the driver.
+func lockedStore(t *testing.T) (*SqliteStore, *sql.Conn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store, err := NewSqliteStore(path+"?_busy_timeout=0", "sessions", sessions.Options{Path: "/", MaxAge: 3600}, []byte("test hash key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	store.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	lockDB, err := sql.Open(DefaultDriverName, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lockDB.Close() })
+	conn, err := lockDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err = conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+	return store, conn
+}
+
+func TestSaveRetriesWhileTheDatabaseIsLocked(t *testing.T) {
+	store, conn := lockedStore(t)
+	store.SetBusyRetry(10, 5*time.Millisecond)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		conn.ExecContext(context.Background(), "COMMIT")
+	}()
+
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("saving once the database has been unlocked failed: %v", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d sessions are stored, want 1", count)
+	}
+}
+
+func TestSaveGivesUpWhileTheDatabaseIsLocked(t *testing.T) {
+	store, _ := lockedStore(t)
+	store.SetBusyRetry(2, time.Millisecond)
+
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); !errors.Is(err, ErrDatabaseBusy) {
+		t.Errorf("saving while the database is locked returned %v, want ErrDatabaseBusy", err)
+	}
+}
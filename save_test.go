@@ -0,0 +1,255 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestConcurrentSavesOfTheSameSession(t *testing.T) {
+	store, clock := newFileTestStore(t)
+	saved := saveSession(t, store, "session", 3600, nil)
+
+	const writers, saves = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*saves)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < saves; j++ {
+				session, err := store.GetByID("session", saved.ID)
+				if err != nil {
+					errs <- err
+					return
+				}
+				session.Values["writer"] = writer
+				clock.Advance(time.Millisecond)
+				if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent save failed: %v", err)
+	}
+
+	loaded, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writer, ok := loaded.Values["writer"].(int); !ok || writer < 0 || writer >= writers {
+		t.Errorf("the stored session has the values %v, want the ones of one of the writers", loaded.Values)
+	}
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d rows are stored, want 1", count)
+	}
+}
+
+func TestSaveStoresADeletedSessionAgain(t *testing.T) {
+	store, _ := newTestStore(t)
+	saved := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"user": "alice"})
+	loaded, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteSession(saved.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), loaded); err != nil {
+		t.Fatalf("saving the deleted session failed: %v", err)
+	}
+	stored, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatalf("the deleted session hasn't been stored again: %v", err)
+	}
+	if stored.Values["user"] != "alice" {
+		t.Errorf("the session stored again has the values %v", stored.Values)
+	}
+}
+
+func TestModifiedOnNeverGoesBackwards(t *testing.T) {
+	store, clock := newTestStore(t)
+	saved := saveSession(t, store, "session", 3600, nil)
+	session, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	//a save whose clock is behind, e.g. on another server, doesn't move the modification time back
+	clock.Set(testEpoch.Add(time.Second))
+	if err = store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	var modifiedOn time.Time
+	if err = store.queryRow(context.Background(), "SELECT modified_on FROM sessions WHERE id = ?", saved.ID).Scan(&modifiedOn); err != nil {
+		t.Fatal(err)
+	}
+	if !modifiedOn.Equal(testEpoch.Add(time.Minute)) {
+		t.Errorf("the modification time is %v, want %v", modifiedOn, testEpoch.Add(time.Minute))
+	}
+}
+
+func TestConcurrentSavesRacingWithDeletion(t *testing.T) {
+	store, _ := newFileTestStore(t)
+	saved := saveSession(t, store, "session", 3600, nil)
+
+	const writers = 4
+	var wg sync.WaitGroup
+	deleted := make(chan struct{})
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		session, err := store.GetByID("session", saved.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(writer int, session *sessions.Session) {
+			defer wg.Done()
+			for {
+				session.Values["writer"] = writer
+				if err := store.Save(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+					errs <- fmt.Errorf("writer %d: %w", writer, err)
+					return
+				}
+				select {
+				case <-deleted:
+					//this save started after the deletion
+					return
+				default:
+				}
+			}
+		}(i, session)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := store.DeleteSession(saved.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(deleted)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	//the saves which happened after the deletion stored the session again, once
+	if count := countRows(t, store, "sessions"); count != 1 {
+		t.Errorf("%d rows are stored, want 1", count)
+	}
+	loaded, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatalf("unable to load the session saved after its deletion: %v", err)
+	}
+	if writer, ok := loaded.Values["writer"].(int); !ok || writer < 0 || writer >= writers {
+		t.Errorf("the stored session has the values %v, want the ones of one of the writers", loaded.Values)
+	}
+}
+
+func TestSaveWithNegativeMaxAgeDeletesTheSession(t *testing.T) {
+	store, _ := newTestStore(t)
+	w := httptest.NewRecorder()
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["user"] = "alice"
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Get(newRequest(w), "session")
+	if err != nil || loaded.IsNew {
+		t.Fatalf("unable to load the saved session: %v", err)
+	}
+	loaded.Options.MaxAge = -1
+	logout := httptest.NewRecorder()
+	if err = store.Save(newRequest(w), logout, loaded); err != nil {
+		t.Fatalf("unable to log out: %v", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions are stored after the logout, want none", count)
+	}
+	if len(loaded.Values) != 0 {
+		t.Errorf("the session still has the values %v after the logout", loaded.Values)
+	}
+	cookies := logout.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].MaxAge >= 0 {
+		t.Errorf("the logout set the cookies %v, want an expired session cookie", cookies)
+	}
+}
+
+func TestSaveAndReturnReturnsTheWrittenSession(t *testing.T) {
+	store, clock := newTestStore(t)
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Options.MaxAge = 3600
+	session.Values["user"] = "alice"
+	record, err := store.SaveAndReturn(newRequest(nil), httptest.NewRecorder(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID == "" || record.ID != session.ID || record.Name != "session" || record.Values["user"] != "alice" {
+		t.Errorf("saving the new session returned %+v, want its ID %q, name and values", record, session.ID)
+	}
+	if !record.CreatedOn.Equal(testEpoch) || !record.ExpiresOn.Equal(testEpoch.Add(time.Hour)) {
+		t.Errorf("the new session has been created on %v and expires on %v", record.CreatedOn, record.ExpiresOn)
+	}
+
+	clock.Advance(time.Minute)
+	if session, err = store.GetByID("session", record.ID); err != nil {
+		t.Fatal(err)
+	}
+	session.Options.MaxAge = 3600
+	if record, err = store.SaveAndReturn(newRequest(nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != session.ID || !record.CreatedOn.Equal(testEpoch) || !record.ExpiresOn.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("updating the session returned %+v, want it expiring on %v", record, clock.Now().Add(time.Hour))
+	}
+
+	session.Options.MaxAge = -1
+	if record, err = store.SaveAndReturn(newRequest(nil), httptest.NewRecorder(), session); err != nil || record != nil {
+		t.Errorf("deleting the session returned %+v and error %v, want no record", record, err)
+	}
+}
+
+func TestSaveWithExpiry(t *testing.T) {
+	store, _ := newTestStore(t)
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := testEpoch.Add(90*time.Minute + 500*time.Millisecond)
+	if err = store.SaveWithExpiry(newRequest(nil), httptest.NewRecorder(), session, expiresAt); err != nil {
+		t.Fatalf("unable to save the session: %v", err)
+	}
+	if session.Options.MaxAge != 5401 {
+		t.Errorf("the session has MaxAge %d, want the seconds until its expiry rounded up, 5401", session.Options.MaxAge)
+	}
+	loaded, err := store.GetByID("session", session.ID)
+	if err != nil {
+		t.Fatalf("unable to load the saved session: %v", err)
+	}
+	if got, _ := loaded.Values["expires_on"].(time.Time); !got.Equal(expiresAt) {
+		t.Errorf("the saved session expires on %v, want %v", got, expiresAt)
+	}
+
+	if err = store.SaveWithExpiry(newRequest(nil), httptest.NewRecorder(), session, testEpoch); !errors.Is(err, ErrExpiryInPast) {
+		t.Errorf("saving the session with an expiry in the past returned %v, want ErrExpiryInPast", err)
+	}
+}
@@ -0,0 +1,176 @@
+package sqlitestore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Schema names the table the sessions are stored in and its columns.
+//
+// The names are interpolated into the SQL statements, so they are validated when the store is created: the table
+// name can't contain backticks, while the column names can only contain letters, digits and underscores, and can't
+// start with a digit. Every name is enclosed in backticks in the statements, so the names can be SQL keywords, such
+// as order, and mixed-case names are matched as SQLite does, regardless of their case. Unlike double quotes, which
+// SQLite treats as a string literal when they don't name a column, backticks always quote an identifier.
+type Schema struct {
+	Table            string
+	IDColumn         string
+	DataColumn       string
+	CreatedOnColumn  string
+	ModifiedOnColumn string
+	ExpiresOnColumn  string
+	NameColumn       string
+	OwnerColumn      string
+	DeletedAtColumn  string
+	LastAccessColumn string
+	ClientIPColumn   string
+	UserAgentColumn  string
+
+	// SkipTableCreation disables the creation of the table and its indexes, which must then exist, along with all the
+	// columns, before the store is created, the ID column being the primary key: otherwise the constructors return an
+	// error, wrapping ErrTableMissing if the table doesn't exist. By default the store creates them if they don't exist
+	// yet, upgrading the tables created by older versions of the store (see Migrate).
+	SkipTableCreation bool
+
+	// TextIDs creates the ID column as a TEXT PRIMARY KEY rather than an INTEGER PRIMARY KEY, so that it can store the
+	// IDs of an ID generator, e.g. ULIDs (see SqliteStore.SetIDGenerator). Until a generator is set, the store generates
+	// random IDs itself, as SQLite doesn't assign the IDs of a TEXT column. It only affects the creation of the table:
+	// the ID column of an existing table keeps its type.
+	TextIDs bool
+
+	// ExtraDDL are statements run right after the table has been created by the store, e.g. to add the columns and
+	// indexes of the application, in the same transaction as the creation of the table, so that either the table is
+	// created along with all of them or it isn't created at all. They are only run when the table is created, not when
+	// it already exists. The store ignores the columns it doesn't know about, so they must be nullable or have a
+	// default value, as the store doesn't set them.
+	ExtraDDL []string
+}
+
+// DefaultSchema returns the schema the store uses by default, with the given table name.
+func DefaultSchema(tableName string) Schema {
+	return Schema{
+		Table:            tableName,
+		IDColumn:         "id",
+		DataColumn:       "session_data",
+		CreatedOnColumn:  "created_on",
+		ModifiedOnColumn: "modified_on",
+		ExpiresOnColumn:  "expires_on",
+		NameColumn:       "session_name",
+		OwnerColumn:      "owner",
+		DeletedAtColumn:  "deleted_at",
+		LastAccessColumn: "last_access",
+		ClientIPColumn:   "client_ip",
+		UserAgentColumn:  "user_agent",
+	}
+}
+
+var columnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// normalize validates the names of the schema and returns it with the names enclosed in backticks, and the default
+// name for each column whose name is empty. Normalizing a schema which has already been normalized returns it as it
+// is.
+func (s Schema) normalize() (Schema, error) {
+	table := unquote(s.Table)
+	if table == "" || strings.ContainsAny(table, "`\x00") {
+		return Schema{}, fmt.Errorf("invalid table name %q", s.Table)
+	}
+	s.Table = "`" + table + "`"
+
+	defaults := DefaultSchema("")
+	for _, column := range []struct {
+		name         *string
+		defaultValue string
+	}{
+		{&s.IDColumn, defaults.IDColumn},
+		{&s.DataColumn, defaults.DataColumn},
+		{&s.CreatedOnColumn, defaults.CreatedOnColumn},
+		{&s.ModifiedOnColumn, defaults.ModifiedOnColumn},
+		{&s.ExpiresOnColumn, defaults.ExpiresOnColumn},
+		{&s.NameColumn, defaults.NameColumn},
+		{&s.OwnerColumn, defaults.OwnerColumn},
+		{&s.DeletedAtColumn, defaults.DeletedAtColumn},
+		{&s.LastAccessColumn, defaults.LastAccessColumn},
+		{&s.ClientIPColumn, defaults.ClientIPColumn},
+		{&s.UserAgentColumn, defaults.UserAgentColumn},
+	} {
+		name := unquote(*column.name)
+		if name == "" {
+			name = column.defaultValue
+		}
+		if !columnNameRegexp.MatchString(name) {
+			return Schema{}, fmt.Errorf("invalid column name %q", *column.name)
+		}
+		*column.name = "`" + name + "`"
+	}
+	return s, nil
+}
+
+// indexName returns the quoted name of the index on column.
+func (s Schema) indexName(column string) string {
+	return "`" + s.unquotedTable() + "_" + unquote(column) + "_idx`"
+}
+
+// unquotedTable returns the table name without the enclosing backticks.
+func (s Schema) unquotedTable() string {
+	return unquote(s.Table)
+}
+
+// unquote returns the name without the enclosing backticks.
+func unquote(name string) string {
+	return strings.Trim(name, "`")
+}
+
+// expiredCondition returns the WHERE clause which matches the expired sessions which haven't been soft-deleted,
+// it must be bound to the current time, less the expiry grace period (see SqliteStore.expiryCutoff).
+// The timestamps are compared through julianday so that they are compared as instants, regardless of the time zone
+// offset they have been stored with.
+func (s Schema) expiredCondition() string {
+	return " WHERE julianday(" + s.ExpiresOnColumn + ") < julianday(?)" + s.liveCondition()
+}
+
+// expiredPageCondition returns the condition which selects the rows following the last row of the previous page of
+// expired sessions, in the order of SqliteStore.expiredBatchLimit, so that the rows a cleanup doesn't delete, e.g.
+// because they can't be read, aren't selected again by the following pages. It must be bound to the julianday of the
+// expiry of the last row, three times, then to its ID, twice; an expiry bound to NULL selects the first page.
+func (s Schema) expiredPageCondition() string {
+	expiry := "julianday(" + s.ExpiresOnColumn + ")"
+	return " AND (? IS NULL OR " + expiry + " > ? OR (" + expiry + " = ? AND " + s.IDColumn + " IS NOT NULL AND (? IS NULL OR " +
+		s.IDColumn + " > ?)))"
+}
+
+// activeCondition returns the WHERE clause which matches the sessions which are neither expired nor soft-deleted,
+// it must be bound to the same time as expiredCondition.
+func (s Schema) activeCondition() string {
+	return " WHERE julianday(" + s.ExpiresOnColumn + ") >= julianday(?)" + s.liveCondition()
+}
+
+// liveCondition is the condition which excludes the soft-deleted sessions, it is part of expiredCondition and
+// activeCondition as well.
+func (s Schema) liveCondition() string {
+	return " AND " + s.DeletedAtColumn + " IS NULL"
+}
+
+// selectQuery returns the query which selects the session with a given ID, unless it has been soft-deleted.
+func (s Schema) selectQuery() string {
+	return "SELECT " + s.IDColumn + ", " + s.DataColumn + ", " + s.CreatedOnColumn + ", " + s.ModifiedOnColumn + ", " +
+		s.ExpiresOnColumn + ", " + s.ClientIPColumn + ", " + s.UserAgentColumn + ", " + s.LastAccessColumn + " from " + s.Table +
+		" WHERE " + s.IDColumn + " = ?" + s.liveCondition()
+}
+
+// existsQuery returns the query which selects a row if the session with a given ID and name is active, it must be
+// bound to the time activeCondition is bound to, the ID and the name twice, an empty name matching every name.
+func (s Schema) existsQuery() string {
+	return "SELECT 1 FROM " + s.Table + s.activeCondition() + " AND " + s.IDColumn + " = ?" +
+		" AND (? = '' OR " + s.NameColumn + " = ? OR " + s.NameColumn + " IS NULL) LIMIT 1"
+}
+
+// nameCondition returns the condition which restricts a query to the sessions named sessionName, along with the
+// arguments it must be bound to. An empty sessionName matches every session. The rows written before the session name
+// was stored have no name, so they match every session name.
+func (s Schema) nameCondition(sessionName string) (string, []interface{}) {
+	if sessionName == "" {
+		return "", nil
+	}
+	return " AND (" + s.NameColumn + " = ? OR " + s.NameColumn + " IS NULL)", []interface{}{sessionName}
+}
@@ -0,0 +1,70 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/maxbarbieri/sqlitestore/sqlitestoretest"
+)
+
+func TestReservedWordsInTheSchema(t *testing.T) {
+	db, err := sql.Open(DefaultDriverName, "file:reserved_words?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := sqlitestoretest.NewFakeClock(testEpoch)
+	store, err := New(db, "order", WithSchema(Schema{NameColumn: "order", OwnerColumn: "group"}),
+		WithKeyPairs([]byte("test hash key")), WithClock(clock), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		t.Fatalf("unable to create the store on the table order: %v", err)
+	}
+	defer store.Close()
+	store.SetOwnerKey("user")
+
+	w := httptest.NewRecorder()
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["user"] = "alice"
+	session.Options.MaxAge = 60
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatalf("unable to save the session: %v", err)
+	}
+	loaded, err := store.New(newRequest(w), "session")
+	if err != nil || loaded.IsNew || loaded.Values["user"] != "alice" {
+		t.Fatalf("loaded the session %v with error %v, want the saved one", loaded.Values, err)
+	}
+	loaded.Options.MaxAge = 60
+	if err = store.Save(newRequest(w), httptest.NewRecorder(), loaded); err != nil {
+		t.Fatalf("unable to update the session: %v", err)
+	}
+	if count, err := store.ActiveSessionCount("session"); err != nil || count != 1 {
+		t.Errorf("counted %d active sessions with error %v, want 1", count, err)
+	}
+	if userSessions, err := store.SessionsForUser("alice"); err != nil || len(userSessions) != 1 {
+		t.Errorf("got %d sessions of the user with error %v, want 1", len(userSessions), err)
+	}
+
+	saveSession(t, store, "session", 3600, nil)
+	var preDeleted int
+	store.SetExpiredSessionPreDeleteCallback(func(*sessions.Session) { preDeleted++ })
+	clock.Advance(2 * time.Minute)
+	if deleted, err := store.CleanupNow("session"); err != nil || deleted != 1 || preDeleted != 1 {
+		t.Errorf("cleanup deleted %d sessions, calling the callback %d times, with error %v, want 1, 1 and no error", deleted, preDeleted, err)
+	}
+	store.SetExpiredSessionPreDeleteCallback(nil)
+	clock.Advance(2 * time.Hour)
+	if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+		t.Errorf("the cleanup with a single statement deleted %d sessions with error %v, want 1 and no error", deleted, err)
+	}
+	if count := countRows(t, store, "order"); count != 0 {
+		t.Errorf("%d sessions are left, want none", count)
+	}
+}
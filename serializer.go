@@ -0,0 +1,105 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrSerialization is wrapped by the error Save returns when the session values can't be encoded, whichever the
+// serializer, e.g. because a value has a type the serializer can't encode.
+var ErrSerialization = errors.New("Unable to serialize the session values")
+
+// Serializer encodes the values of the sessions into the data stored in the database, and decodes them back.
+type Serializer interface {
+	Serialize(values map[interface{}]interface{}) ([]byte, error)
+	Deserialize(data []byte, session *sessions.Session) error
+}
+
+// GobSerializer encodes the session values with encoding/gob. Like with gob in general, the concrete types stored in
+// the values must be registered with gob.Register.
+type GobSerializer struct{}
+
+func (GobSerializer) Serialize(values map[interface{}]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, fmt.Errorf("unable to gob encode the session values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobSerializer) Deserialize(data []byte, session *sessions.Session) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values)
+}
+
+// JSONSerializer encodes the session values as a JSON object, so that the stored data can be inspected and read by
+// other languages. The keys of the values must be strings, and the values must be encodable with encoding/json:
+// serializing anything else fails instead of storing data which can't be decoded back. As usual with JSON, the values
+// are decoded back as strings, float64s, bools, nils, []interface{} and map[string]interface{}.
+type JSONSerializer struct{}
+
+func (JSONSerializer) Serialize(values map[interface{}]interface{}) ([]byte, error) {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unable to JSON encode the session values: non-string key %#v", k)
+		}
+		m[ks] = v
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to JSON encode the session values: %w", err)
+	}
+	return data, nil
+}
+
+func (JSONSerializer) Deserialize(data []byte, session *sessions.Session) error {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		session.Values[k] = v
+	}
+	return nil
+}
+
+// SetSerializer sets the serializer used to encode the session values in the database. By default the values are
+// encoded with the store codecs, like the cookies are. The sessions already stored with a different serializer can
+// still be decoded if it is a FormatSerializer, see RegisterSerializer; otherwise changing the serializer of a store
+// makes them unreadable.
+func (m *SqliteStore) SetSerializer(serializer Serializer) {
+	m.serializer = serializer
+}
+
+// serialize encodes the session values with the serializer, or with the codecs if no serializer has been set, its
+// errors wrapping ErrSerialization.
+func (m *SqliteStore) serialize(session *sessions.Session) ([]byte, error) {
+	var data []byte
+	var err error
+	if m.serializer == nil {
+		var encoded string
+		encoded, err = securecookie.EncodeMulti(session.Name(), session.Values, m.valueCodecs()...)
+		data = []byte(encoded)
+	} else {
+		data, err = m.serializer.Serialize(session.Values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSerialization, err)
+	}
+	return data, nil
+}
+
+// deserialize decodes the session values with the serializer, or with the codecs if no serializer has been set.
+func (m *SqliteStore) deserialize(data []byte, session *sessions.Session) error {
+	if m.serializer == nil {
+		return securecookie.DecodeMulti(session.Name(), string(data), &session.Values, m.valueCodecs()...)
+	}
+	return m.serializer.Deserialize(data, session)
+}
@@ -0,0 +1,17 @@
+package sqlitestore
+
+import "testing"
+
+func TestSerializersRoundTrip(t *testing.T) {
+	for _, serializer := range []Serializer{GobSerializer{}, JSONSerializer{}} {
+		store, _ := newTestStore(t, WithSerializer(serializer))
+		saved := saveSession(t, store, "session", 3600, map[interface{}]interface{}{"user": "alice"})
+		loaded, err := store.GetByID("session", saved.ID)
+		if err != nil {
+			t.Fatalf("unable to load the session serialized with %T: %v", serializer, err)
+		}
+		if loaded.Values["user"] != "alice" {
+			t.Errorf("the values deserialized with %T are %v", serializer, loaded.Values)
+		}
+	}
+}
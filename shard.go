@@ -0,0 +1,138 @@
+package sqlitestore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SetTableForName makes the store keep the sessions named name in their own table rather than in the table of the
+// store, e.g. to keep the cleanup of a high volume session name from scanning the sessions of the other names, and
+// vice versa. The table is created in the database of the store, with the same columns, unless the schema of the
+// store has SkipTableCreation set, in which case it must already exist. The sessions which are already stored in the
+// table of the store under that name are not moved.
+//
+// The sessions named name are then loaded, saved and deleted in their table by New, Get, Save, Delete, GetByID,
+// GetByIDEvenIfExpired, SessionExists, Touch, DeleteSessionByName, ActiveSessionCount, Counts and the cleanups of
+// that name, while the cleanups of every name, started with StartCleanupAll or run with CleanupNow(""), clean up
+// every table. The other operations, such as ForEachSession, only see the table of the store: they must be run on the
+// returned store, which manages the table of the name.
+//
+// The returned store shares the database of the store and starts with a copy of its configuration as it is when the
+// table is mapped: the codecs, the options of the sessions, the serializers, the compression, the encryption, the
+// owner key and the limit of sessions per user, the callbacks and the handlers of the cleanup, the cache, the clock
+// and the logger, among the others. The settings changed afterwards on the store don't affect it, nor do the
+// subscribers of Events and the cleanup observers, which must be set on it as well. It is closed along with the store.
+// Mapping the name to another table replaces the previous mapping, closing its store.
+//
+// DeleteAll with an empty name, DeleteAllByUser and SessionsForUser cover the tables of every name as well.
+func (m *SqliteStore) SetTableForName(name string, table string) (*SqliteStore, error) {
+	if m.closed.Load() {
+		return nil, ErrStoreClosed
+	}
+	if name == "" {
+		return nil, errors.New("the session name mapped to a table can't be empty")
+	}
+
+	schema := m.schema
+	schema.Table = table
+	schema.ExtraDDL = nil
+	shard, err := NewSqliteStoreWithSchema(m.db, schema, *m.Options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up the table of the sessions named %q: %w", name, err)
+	}
+	shard.sharedDB = true
+	if err = m.configureShard(shard); err != nil {
+		shard.Close()
+		return nil, fmt.Errorf("unable to set up the table of the sessions named %q: %w", name, err)
+	}
+
+	m.shardsMu.Lock()
+	previous := m.shards[name]
+	if m.shards == nil {
+		m.shards = make(map[string]*SqliteStore)
+	}
+	m.shards[name] = shard
+	m.shardsMu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return shard, nil
+}
+
+// configureShard copies the configuration of the store to the store of the table of a session name.
+func (m *SqliteStore) configureShard(shard *SqliteStore) error {
+	m.codecsMu.RLock()
+	shard.Codecs, shard.keyPairs, shard.codecsMaxAge = m.Codecs, m.keyPairs, m.codecsMaxAge
+	m.codecsMu.RUnlock()
+	shard.clock, shard.loc, shard.logger, shard.tracer = m.clock, m.loc, m.logger, m.tracer
+
+	shard.serializer = m.serializer
+	for format, serializer := range m.serializers {
+		if shard.serializers == nil {
+			shard.serializers = make(map[byte]Serializer, len(m.serializers))
+		}
+		shard.serializers[format] = serializer
+	}
+	shard.compression, shard.compressionThreshold, shard.jsonText = m.compression, m.compressionThreshold, m.jsonText
+	shard.aead, shard.maxValueSize, shard.valueSizeObserver = m.aead, m.maxValueSize, m.valueSizeObserver
+	shard.ownerKey, shard.maxSessionsPerUser = m.ownerKey, m.maxSessionsPerUser
+	shard.idGenerator, shard.softDelete, shard.recordClient = m.idGenerator, m.softDelete, m.recordClient
+	shard.dropCorruptSessions = m.dropCorruptSessions
+	shard.expiryGrace, shard.idleTimeout = m.expiryGrace, m.idleTimeout
+	shard.slidingExpiration, shard.slidingExpirationThreshold = m.slidingExpiration, m.slidingExpirationThreshold
+	shard.busyRetries, shard.busyBackoff = m.busyRetries, m.busyBackoff
+	shard.vacuumMode, shard.vacuumThreshold = m.vacuumMode, m.vacuumThreshold
+
+	m.callbacksMu.RLock()
+	shard.expiredSessionPreDeleteCallback = m.expiredSessionPreDeleteCallback
+	shard.expiredSessionPostDeleteCallback = m.expiredSessionPostDeleteCallback
+	shard.expiredSessionsBatchCallback = m.expiredSessionsBatchCallback
+	shard.cleanupErrorHandler, shard.cleanupTickCallback = m.cleanupErrorHandler, m.cleanupTickCallback
+	m.callbacksMu.RUnlock()
+	shard.cleanupDeleteChunkSize, shard.cleanupBatchSize, shard.cleanupJitter = m.cleanupDeleteChunkSize, m.cleanupBatchSize, m.cleanupJitter
+	shard.cleanupDryRun.Store(m.cleanupDryRun.Load())
+
+	if cache := m.cache.Load(); cache != nil {
+		shard.SetCache(cache.size, cache.ttl)
+	}
+	if m.auditLog {
+		if err := shard.SetAuditLog(true); err != nil {
+			return err
+		}
+	}
+	if m.readDB != nil {
+		return shard.SetReadDB(m.readDB)
+	}
+	return nil
+}
+
+// shard returns the store of the table the sessions named name are kept in, nil if they are kept in the table of the
+// store.
+func (m *SqliteStore) shard(name string) *SqliteStore {
+	if name == "" {
+		return nil
+	}
+	m.shardsMu.RLock()
+	defer m.shardsMu.RUnlock()
+	return m.shards[name]
+}
+
+// allShards returns the stores of the tables of the session names mapped with SetTableForName.
+func (m *SqliteStore) allShards() []*SqliteStore {
+	m.shardsMu.RLock()
+	defer m.shardsMu.RUnlock()
+	shards := make([]*SqliteStore, 0, len(m.shards))
+	for _, shard := range m.shards {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// closeShards closes the stores of the tables of the session names mapped with SetTableForName.
+func (m *SqliteStore) closeShards() error {
+	var errs []error
+	for _, shard := range m.allShards() {
+		errs = append(errs, shard.Close())
+	}
+	return errors.Join(errs...)
+}
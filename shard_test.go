@@ -0,0 +1,62 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestShardCopiesTheConfiguration(t *testing.T) {
+	store, _ := newTestStore(t, WithEncryptionKey(bytes.Repeat([]byte{1}, 32)))
+	store.SetOwnerKey("user")
+	store.SetMaxSessionsPerUser(1)
+	if _, err := store.SetTableForName("big", "big_sessions"); err != nil {
+		t.Fatal(err)
+	}
+
+	first := saveSession(t, store, "big", 3600, map[interface{}]interface{}{"user": "alice", "secret": "plaintext"})
+	second := saveSession(t, store, "big", 3600, map[interface{}]interface{}{"user": "alice", "secret": "plaintext"})
+
+	var data []byte
+	var owner string
+	if err := store.queryRow(context.Background(), "SELECT session_data, owner FROM big_sessions WHERE id = ?", second.ID).Scan(&data, &owner); err != nil {
+		t.Fatalf("the session has not been stored in the table of its name: %v", err)
+	}
+	if bytes.Contains(data, []byte("plaintext")) {
+		t.Error("the session stored in the table of its name is not encrypted")
+	}
+	if owner != "alice" {
+		t.Errorf("the session stored in the table of its name is owned by %q, want alice", owner)
+	}
+	if exists, err := store.SessionExists("big", first.ID); err != nil || exists {
+		t.Errorf("the oldest session of the owner exists: %t, %v; want it evicted", exists, err)
+	}
+}
+
+func TestUserOperationsCoverEveryTable(t *testing.T) {
+	store, _ := newTestStore(t)
+	store.SetOwnerKey("user")
+	if _, err := store.SetTableForName("big", "big_sessions"); err != nil {
+		t.Fatal(err)
+	}
+	saveSession(t, store, "small", 3600, map[interface{}]interface{}{"user": "alice"})
+	saveSession(t, store, "big", 3600, map[interface{}]interface{}{"user": "alice"})
+	saveSession(t, store, "big", 3600, map[interface{}]interface{}{"user": "bob"})
+
+	userSessions, err := store.SessionsForUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userSessions) != 2 {
+		t.Errorf("got %d sessions of the user, want 2", len(userSessions))
+	}
+	if deleted, err := store.DeleteAllByUser("alice"); err != nil || deleted != 2 {
+		t.Errorf("deleted %d sessions of the user with error %v, want 2 and no error", deleted, err)
+	}
+	if deleted, err := store.DeleteAll(""); err != nil || deleted != 1 {
+		t.Errorf("deleted %d sessions with error %v, want 1 and no error", deleted, err)
+	}
+	if count := countRows(t, store, "big_sessions"); count != 0 {
+		t.Errorf("%d sessions are left in the table of the name, want none", count)
+	}
+}
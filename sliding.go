@@ -0,0 +1,64 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// SetSlidingExpiration sets whether loading a session through Get pushes its expiry forward by MaxAge, so that the
+// sessions which are in use don't expire. To avoid writing on every request, the expiry is only extended once the
+// threshold set with SetSlidingExpirationThreshold has elapsed since it was last set. Disabled by default.
+func (m *SqliteStore) SetSlidingExpiration(enabled bool) {
+	m.slidingExpiration = enabled
+}
+
+// SetSlidingExpirationThreshold sets how long after its expiry has been last set a loaded session gets its expiry
+// extended again. A value <= 0 restores the default, which is half of MaxAge.
+func (m *SqliteStore) SetSlidingExpirationThreshold(threshold time.Duration) {
+	m.slidingExpirationThreshold = threshold
+}
+
+// slideExpiration extends the expiry of the loaded session if sliding expiration is enabled and the threshold has
+// elapsed, by the MaxAge of the session, or by the one of the store if the session has none. The expiry of a session
+// which has expired in the meantime is left untouched. Failures are only logged, as the session has been loaded anyway.
+func (m *SqliteStore) slideExpiration(ctx context.Context, session *sessions.Session) {
+	seconds := m.Options.MaxAge
+	if session.Options != nil && session.Options.MaxAge != 0 {
+		seconds = session.Options.MaxAge
+	}
+	if !m.slidingExpiration || seconds <= 0 {
+		return
+	}
+	expiresOn, ok := session.Values["expires_on"].(time.Time)
+	if !ok {
+		return
+	}
+
+	maxAge := time.Second * time.Duration(seconds)
+	threshold := m.slidingExpirationThreshold
+	if threshold <= 0 {
+		threshold = maxAge / 2
+	}
+	now := m.now()
+	if elapsed := maxAge - expiresOn.Sub(now); elapsed <= threshold {
+		return
+	}
+
+	newExpiresOn := now.Add(maxAge)
+	//extending the expiry records the access as well, see SetIdleTimeout
+	res, err := m.stmtExtend.ExecContext(ctx, timestamp(newExpiresOn), timestamp(now), timestamp(m.expiryCutoff()), session.ID)
+	if err != nil {
+		m.log().Error("Error extending session expiry", "session_id", session.ID, "error", err)
+		return
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		session.Values["expires_on"] = newExpiresOn
+		m.cacheUpdated(ctx, session.ID, func(row *sessionRow) {
+			row.expiresOn = newExpiresOn
+			row.lastAccess = sql.NullTime{Time: now, Valid: true}
+		})
+	}
+}
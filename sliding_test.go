@@ -0,0 +1,72 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// storedExpiry returns the expiry stored for the session with the given ID.
+func storedExpiry(t *testing.T, store *SqliteStore, id string) time.Time {
+	t.Helper()
+	var expiresOn time.Time
+	if err := store.queryRow(context.Background(), "SELECT expires_on FROM sessions WHERE id = ?", id).Scan(&expiresOn); err != nil {
+		t.Fatal(err)
+	}
+	return expiresOn
+}
+
+func TestSlidingExpirationExtendsTheLoadedSessions(t *testing.T) {
+	store, clock := newTestStore(t, WithSessionOptions(sessions.Options{Path: "/", MaxAge: 3600}))
+	store.SetSlidingExpiration(true)
+	session, err := store.New(newRequest(nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Options.MaxAge = 3600
+	w := httptest.NewRecorder()
+	if err = store.Save(newRequest(nil), w, session); err != nil {
+		t.Fatal(err)
+	}
+
+	//within the threshold, half of MaxAge, the expiry is left as it is
+	clock.Advance(20 * time.Minute)
+	if _, err = store.New(newRequest(w), "session"); err != nil {
+		t.Fatal(err)
+	}
+	if expiresOn := storedExpiry(t, store, session.ID); !expiresOn.Equal(testEpoch.Add(time.Hour)) {
+		t.Errorf("the session expires on %v within the threshold, want %v", expiresOn, testEpoch.Add(time.Hour))
+	}
+
+	clock.Advance(20 * time.Minute)
+	if _, err = store.New(newRequest(w), "session"); err != nil {
+		t.Fatal(err)
+	}
+	if expiresOn := storedExpiry(t, store, session.ID); !expiresOn.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("the session expires on %v after the threshold, want %v", expiresOn, clock.Now().Add(time.Hour))
+	}
+}
+
+func TestSlidingExpirationUsesTheMaxAgeOfTheSession(t *testing.T) {
+	store, clock := newTestStore(t, WithSessionOptions(sessions.Options{Path: "/", MaxAge: 3600}))
+	store.SetSlidingExpiration(true)
+	//a "remember me" session, lasting a day rather than the hour of the store
+	saved := saveSession(t, store, "session", 86400, nil)
+
+	clock.Advance(13 * time.Hour)
+	session, err := store.GetByID("session", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Options.MaxAge = 86400
+	store.slideExpiration(context.Background(), session)
+	if expiresOn := storedExpiry(t, store, saved.ID); !expiresOn.Equal(clock.Now().Add(24 * time.Hour)) {
+		t.Errorf("the session expires on %v, want a day after %v", expiresOn, clock.Now())
+	}
+	if expiresOn, _ := session.Values["expires_on"].(time.Time); !expiresOn.Equal(clock.Now().Add(24 * time.Hour)) {
+		t.Errorf("the loaded session expires on %v, want a day after %v", expiresOn, clock.Now())
+	}
+}
@@ -0,0 +1,73 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SetSoftDelete sets whether the deleted sessions, including the expired ones deleted by the cleanup, are only marked
+// as deleted, by setting their deleted_at column, instead of being removed from the table, e.g. to keep an audit trail.
+// The soft-deleted sessions are treated as if they didn't exist, until PurgeSoftDeleted removes them.
+// Disabled by default.
+func (m *SqliteStore) SetSoftDelete(enabled bool) {
+	m.softDelete = enabled
+}
+
+// PurgeSoftDeleted removes from the table the sessions which have been soft-deleted more than olderThan ago and
+// returns the number of sessions removed.
+func (m *SqliteStore) PurgeSoftDeleted(olderThan time.Duration) (int, error) {
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	stmt, err := m.stmt(context.Background(), "DELETE FROM "+m.table+" WHERE julianday("+m.schema.DeletedAtColumn+") < julianday(?)")
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := m.execRetry(context.Background(), stmt, timestamp(m.now().Add(-olderThan)))
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+// deleteRow deletes the session with the given ID, or marks it as deleted when soft deletion is enabled, emitting its
+// Deleted event with the given name if it existed, and recording it in the audit log as deleted for reason.
+func (m *SqliteStore) deleteRow(ctx context.Context, reason DeletionReason, id string, name string) (sql.Result, error) {
+	var res sql.Result
+	err := m.withAudit(ctx, reason, " WHERE "+m.schema.IDColumn+" = ?", []interface{}{id}, func(ctx context.Context) (err error) {
+		if m.softDelete {
+			res, err = m.execRetry(ctx, txStmt(ctx, m.stmtSoftDelete), timestamp(m.now()), id)
+		} else {
+			res, err = m.execRetry(ctx, txStmt(ctx, m.stmtDelete), id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.invalidateCached(ctx, id)
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		m.emit(ctx, SessionDeleted, id, name)
+	}
+	return res, nil
+}
+
+// withAudit runs the deletion of the sessions matching condition, which is a WHERE clause bound to args, recording
+// them in the audit log as deleted for reason in the same transaction, if the audit log is enabled.
+func (m *SqliteStore) withAudit(ctx context.Context, reason DeletionReason, condition string, args []interface{}, deletion func(ctx context.Context) error) error {
+	if !m.auditLog {
+		return deletion(ctx)
+	}
+	return m.atomically(ctx, func(ctx context.Context) error {
+		if err := m.auditDeletion(ctx, reason, condition, args...); err != nil {
+			return err
+		}
+		return deletion(ctx)
+	})
+}
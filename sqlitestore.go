@@ -7,41 +7,206 @@ This software is licensed under a MIT style license available in the LICENSE fil
 package sqlitestore
 
 import (
+	"context"
+	"crypto/cipher"
 	"database/sql"
 	"encoding/gob"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
-	_ "github.com/mattn/go-sqlite3"
 )
 
+// SqliteStore is a sessions.Store which stores the sessions in a SQLite table, while their cookies only hold their IDs.
+// Like the other gorilla stores, Get returns the session cached in the registry of the request, New loads the session
+// of the cookie of the request, if any, and the sessions are saved with Save or sessions.Save; a negative MaxAge
+// deletes the session, while a MaxAge of 0, which makes the cookie last until the browser is closed, makes the stored
+// session expire as soon as it is saved, so the sessions must have a positive MaxAge to be loaded again.
 type SqliteStore struct {
 	db         DB
+	sharedDB   bool //whether db is managed by the caller, so it must not be closed by the store
 	stmtInsert *sql.Stmt
 	stmtDelete *sql.Stmt
 	stmtUpdate *sql.Stmt
 	stmtSelect *sql.Stmt
+	stmtExtend *sql.Stmt
+	stmtExists *sql.Stmt
+	stmtTouch  *sql.Stmt
+
+	//statement selecting the IDs, names and expiries of the expired sessions for the cleanup, bound to the current time,
+	//the session name (twice, '' for any name), the key of the last row of the previous page (see
+	//Schema.expiredPageCondition) and the size of the page (-1 for no limit)
+	stmtSelectExpired *sql.Stmt
+
+	//statement inserting a session with the ID returned by idGenerator, which is nil by default
+	stmtInsertWithID *sql.Stmt
+	idGenerator      func() string
+
+	//statements of the queries which aren't prepared when the store is created, by query
+	stmts stmtCache
+
+	//handle the queries are run on instead of db, with the statements prepared on it, nil if none has been set
+	readDB         DB
+	stmtReadSelect *sql.Stmt
+	stmtReadExists *sql.Stmt
+	readStmts      stmtCache
+
+	//statement marking a session as deleted, used instead of stmtDelete when softDelete is set
+	stmtSoftDelete *sql.Stmt
+	softDelete     bool
 
 	Codecs  []securecookie.Codec
 	Options *sessions.Options
 	table   string
+	schema  Schema
+
+	//key pairs the codecs have been created from, replaced along with them while holding codecsMu
+	keyPairs [][]byte
+	codecsMu sync.RWMutex
+	//maximum age of the cookies set with MaxAge, the default of securecookie if nil, guarded by codecsMu
+	codecsMaxAge *int
 
 	//callback which gets called for each session before it is deleted for inactivity
 	expiredSessionPreDeleteCallback func(*sessions.Session)
+
+	//callback which gets called for each session after it has been deleted for inactivity
+	expiredSessionPostDeleteCallback func(*sessions.Session)
+
+	//callback which gets called with all the sessions each cleanup is about to delete for inactivity
+	expiredSessionsBatchCallback func([]*sessions.Session)
+
+	//guards the callbacks, which may be set while the cleanup is running
+	callbacksMu sync.RWMutex
+
+	//maximum number of IDs bound to a single DELETE statement by the cleanup
+	cleanupDeleteChunkSize int
+
+	//maximum number of sessions deleted by each cleanup, 0 if it is unlimited
+	cleanupBatchSize int
+
+	//handler which gets called with the error of each failed cleanup
+	cleanupErrorHandler func(error)
+
+	//callback which gets called with the outcome of each tick of the background cleanups
+	cleanupTickCallback func(deleted int, err error)
+
+	//fraction of the interval by which the time between two cleanups is randomly shortened or lengthened
+	cleanupJitter float64
+
+	//outcome of the last background cleanup
+	lastCleanupAt          time.Time
+	lastCleanupDeleted     int
+	lastCleanupWouldDelete int
+	cleanupStatsMu         sync.Mutex
+
+	//whether the background cleanups skip their ticks
+	cleanupPaused atomic.Bool
+	//whether the cleanups only select the expired sessions, see SetCleanupDryRun
+	cleanupDryRun atomic.Bool
+
+	//how long after their expiry the sessions are still active, see SetExpiryGracePeriod
+	expiryGrace time.Duration
+
+	//how long the sessions can go without being accessed before they expire, 0 if they can't, see SetIdleTimeout
+	idleTimeout time.Duration
+
+	//stores of the tables the sessions of some names are kept in, by name, see SetTableForName
+	shards   map[string]*SqliteStore
+	shardsMu sync.RWMutex
+
+	//held while a cleanup is running
+	cleanupRunMu sync.Mutex
+
+	//running background cleanups by session name, and whether the store has been closed
+	cleanups   map[string]*cleanupRun
+	cleanupsMu sync.Mutex
+	closed     atomic.Bool
+
+	//channels the session events are sent to, see Events
+	subscribers   []chan SessionEvent
+	subscribersMu sync.Mutex
+
+	//functions which get called with the outcome of each cleanup
+	cleanupObservers   []func(CleanupResult)
+	cleanupObserversMu sync.Mutex
+
+	//whether loading a session extends its expiry, and how long after the last extension it is extended again
+	slidingExpiration          bool
+	slidingExpirationThreshold time.Duration
+
+	//time zone of the stored timestamps, UTC if nil
+	loc   *time.Location
+	clock Clock
+
+	serializer Serializer
+
+	//serializers registered to decode the data stored in their formats, by format
+	serializers map[byte]Serializer
+
+	//how the database is vacuumed after the cleanups which deleted more than vacuumThreshold sessions
+	vacuumMode      VacuumMode
+	vacuumThreshold int
+
+	//how many times and after how long the writes failed because the database is busy are retried
+	busyRetries int
+	busyBackoff time.Duration
+
+	//whether the serialized values are compressed, and from which size
+	compression          bool
+	compressionThreshold int
+
+	//whether the values are stored as bare JSON text, see SetJSONText
+	jsonText bool
+
+	//cache of the stored sessions, nil if it is disabled
+	cache atomic.Pointer[rowCache]
+
+	//whether the deleted sessions are recorded in the audit log
+	auditLog bool
+
+	//whether the sessions whose data can't be decoded are deleted by New
+	dropCorruptSessions bool
+
+	//whether the IP address and the user agent of the client each session has been created from are recorded
+	recordClient bool
+
+	//whether the store tries to recover from the corruption of the database, and the error of the recovery which
+	//found it corrupt
+	recoveryMode   bool
+	recoveryFailed error
+	recoveryMu     sync.Mutex
+
+	//maximum size of the stored values, 0 if they are unlimited, and the function observing their sizes
+	maxValueSize      int
+	valueSizeObserver func(id string, size int)
+
+	//key of the session value identifying the owner of the session, nil if the owner isn't stored
+	ownerKey interface{}
+	//maximum number of sessions of the same owner, 0 if it is unlimited
+	maxSessionsPerUser int
+
+	//cipher used to encrypt the stored data, nil if it isn't encrypted
+	aead cipher.AEAD
+
+	logger *slog.Logger
+	tracer Tracer
 }
 
 type sessionRow struct {
 	id         string
-	data       string
+	data       []byte
 	createdOn  time.Time
 	modifiedOn time.Time
 	expiresOn  time.Time
+	clientIP   sql.NullString
+	userAgent  sql.NullString
+	lastAccess sql.NullTime
 }
 
 type DB interface {
@@ -50,116 +215,467 @@ type DB interface {
 	Close() error
 }
 
+var (
+	// ErrSessionNotFound is returned when there is no session with the requested ID in the database.
+	ErrSessionNotFound = errors.New("Session not found")
+	// ErrSessionExpired is returned when the requested session exists, but it is expired.
+	ErrSessionExpired = errors.New("Session expired")
+	// ErrStoreClosed is returned when the store is used after it has been closed.
+	ErrStoreClosed = errors.New("Store closed")
+)
+
+var _ sessions.Store = (*SqliteStore)(nil)
+
 func init() {
 	gob.Register(time.Time{})
 }
 
 func NewSqliteStore(endpoint string, tableName string, sessionsOptions sessions.Options, keyPairs ...[]byte) (*SqliteStore, error) {
-	db, err := sql.Open("sqlite3", endpoint)
+	return NewSqliteStoreWithDriver(DefaultDriverName, endpoint, tableName, sessionsOptions, keyPairs...)
+}
+
+// NewSqliteStoreFromDB creates a store which uses db, a database handle managed by the caller, e.g. because it is
+// shared with the rest of the application. The store only prepares its statements and creates its table if it doesn't
+// exist; it never closes db, not even in Close, so the caller remains responsible for closing it once the store has
+// been closed. The sessions get the same default options as the gorilla cookie store: Path "/" and a MaxAge of 30 days.
+//
+// Unlike NewSqliteStoreFromDB, NewSqliteStoreFromConnection takes ownership of the connection, which gets closed by Close.
+func NewSqliteStoreFromDB(db *sql.DB, tableName string, keyPairs ...[]byte) (*SqliteStore, error) {
+	store, err := NewSqliteStoreFromConnection(db, tableName, sessions.Options{Path: "/", MaxAge: 86400 * 30}, keyPairs...)
 	if err != nil {
 		return nil, err
 	}
-
-	return NewSqliteStoreFromConnection(db, tableName, sessionsOptions, keyPairs...)
+	store.sharedDB = true
+	return store, nil
 }
 
 func NewSqliteStoreFromConnection(db DB, tableName string, sessionsOptions sessions.Options, keyPairs ...[]byte) (*SqliteStore, error) {
-	// Make sure table name is enclosed.
-	tableName = "`" + strings.Trim(tableName, "`") + "`"
-
-	cTableQ := "CREATE TABLE IF NOT EXISTS " +
-		tableName + " (id INTEGER PRIMARY KEY, " +
-		"session_data LONGBLOB, " +
-		"created_on TIMESTAMP DEFAULT 0, " +
-		"modified_on TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
-		"expires_on TIMESTAMP DEFAULT 0);"
-	if _, err := db.Exec(cTableQ); err != nil {
+	return NewSqliteStoreWithSchema(db, DefaultSchema(tableName), sessionsOptions, keyPairs...)
+}
+
+// NewSqliteStoreWithSchema is like NewSqliteStoreFromConnection, but it stores the sessions in the table and columns
+// named by schema. The columns whose names are empty get their default names.
+func NewSqliteStoreWithSchema(db DB, schema Schema, sessionsOptions sessions.Options, keyPairs ...[]byte) (store *SqliteStore, err error) {
+	if schema, err = schema.normalize(); err != nil {
 		return nil, err
 	}
+	tableName := schema.Table
+
+	if !schema.SkipTableCreation {
+		if err = migrate(db, schema, discardLogger); err != nil {
+			return nil, err
+		}
+	} else if exists, err := tableExists(db, schema); err != nil {
+		return nil, err
+	} else if !exists {
+		//the statements can't be prepared without the table, report why rather than the error of the first of them
+		return nil, fmt.Errorf("%w: %s", ErrTableMissing, schema.unquotedTable())
+	}
+
+	//the statements prepared so far, closed if a later one can't be prepared
+	var prepared []*sql.Stmt
+	defer func() {
+		if err != nil {
+			for _, stmt := range prepared {
+				stmt.Close()
+			}
+		}
+	}()
+	prepare := func(query string) (*sql.Stmt, error) {
+		stmt, err := db.Prepare(query)
+		if err == nil {
+			prepared = append(prepared, stmt)
+		}
+		return stmt, err
+	}
 
 	insQ := "INSERT INTO " + tableName +
-		"(id, session_data, created_on, modified_on, expires_on) VALUES (NULL, ?, ?, ?, ?)"
-	stmtInsert, stmtErr := db.Prepare(insQ)
+		"(" + schema.IDColumn + ", " + schema.DataColumn + ", " + schema.CreatedOnColumn + ", " + schema.ModifiedOnColumn + ", " +
+		schema.ExpiresOnColumn + ", " + schema.NameColumn + ", " + schema.OwnerColumn + ", " + schema.ClientIPColumn + ", " +
+		schema.UserAgentColumn + ") VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtInsert, stmtErr := prepare(insQ)
 	if stmtErr != nil {
 		return nil, stmtErr
 	}
 
-	delQ := "DELETE FROM " + tableName + " WHERE id = ?"
-	stmtDelete, stmtErr := db.Prepare(delQ)
+	insIDQ := "INSERT INTO " + tableName +
+		"(" + schema.IDColumn + ", " + schema.DataColumn + ", " + schema.CreatedOnColumn + ", " + schema.ModifiedOnColumn + ", " +
+		schema.ExpiresOnColumn + ", " + schema.NameColumn + ", " + schema.OwnerColumn + ", " + schema.ClientIPColumn + ", " +
+		schema.UserAgentColumn + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtInsertWithID, stmtErr := prepare(insIDQ)
 	if stmtErr != nil {
 		return nil, stmtErr
 	}
 
-	updQ := "UPDATE " + tableName + " SET session_data = ?, created_on = ?, expires_on = ? WHERE id = ?"
-	stmtUpdate, stmtErr := db.Prepare(updQ)
+	delQ := "DELETE FROM " + tableName + " WHERE " + schema.IDColumn + " = ?"
+	stmtDelete, stmtErr := prepare(delQ)
 	if stmtErr != nil {
 		return nil, stmtErr
 	}
 
-	selQ := "SELECT id, session_data, created_on, modified_on, expires_on from " + tableName + " WHERE id = ?"
-	stmtSelect, stmtErr := db.Prepare(selQ)
+	softDelQ := "UPDATE " + tableName + " SET " + schema.DeletedAtColumn + " = ? WHERE " + schema.IDColumn + " = ?" + schema.liveCondition()
+	stmtSoftDelete, stmtErr := prepare(softDelQ)
 	if stmtErr != nil {
 		return nil, stmtErr
 	}
 
-	return &SqliteStore{
-		db:         db,
-		stmtInsert: stmtInsert,
-		stmtDelete: stmtDelete,
-		stmtUpdate: stmtUpdate,
-		stmtSelect: stmtSelect,
-		Codecs:     securecookie.CodecsFromPairs(keyPairs...),
-		Options: &sessions.Options{
-			Path:     sessionsOptions.Path,
-			MaxAge:   sessionsOptions.MaxAge,
-			Domain:   sessionsOptions.Domain,
-			Secure:   sessionsOptions.Secure,
-			HttpOnly: sessionsOptions.HttpOnly,
-			SameSite: sessionsOptions.SameSite,
-		},
-		table: tableName,
-	}, nil
+	//the sessions are updated with an upsert, so that a session deleted while being saved, e.g. by a concurrent
+	//request, is stored again rather than being lost, and the modification time of a session never goes backwards
+	updQ := "INSERT INTO " + tableName +
+		"(" + schema.IDColumn + ", " + schema.DataColumn + ", " + schema.CreatedOnColumn + ", " + schema.ModifiedOnColumn + ", " +
+		schema.ExpiresOnColumn + ", " + schema.NameColumn + ", " + schema.OwnerColumn + ") VALUES (?, ?, ?, ?, ?, ?, ?)" +
+		" ON CONFLICT (" + schema.IDColumn + ") DO UPDATE SET " + schema.DataColumn + " = excluded." + schema.DataColumn + ", " +
+		schema.CreatedOnColumn + " = excluded." + schema.CreatedOnColumn + ", " +
+		schema.ModifiedOnColumn + " = CASE WHEN julianday(excluded." + schema.ModifiedOnColumn + ") > julianday(" + schema.ModifiedOnColumn + ")" +
+		" OR " + schema.ModifiedOnColumn + " IS NULL THEN excluded." + schema.ModifiedOnColumn + " ELSE " + schema.ModifiedOnColumn + " END, " +
+		schema.ExpiresOnColumn + " = excluded." + schema.ExpiresOnColumn + ", " + schema.NameColumn + " = excluded." + schema.NameColumn + ", " +
+		schema.OwnerColumn + " = excluded." + schema.OwnerColumn
+	stmtUpdate, stmtErr := prepare(updQ)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	stmtSelect, stmtErr := prepare(schema.selectQuery())
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	extQ := "UPDATE " + tableName + " SET " + schema.ExpiresOnColumn + " = ?, " + schema.LastAccessColumn + " = ?" +
+		schema.activeCondition() + " AND " + schema.IDColumn + " = ?"
+	stmtExtend, stmtErr := prepare(extQ)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	stmtExists, stmtErr := prepare(schema.existsQuery())
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	touchQ := "UPDATE " + tableName + " SET " + schema.LastAccessColumn + " = ?" + schema.activeCondition() +
+		" AND " + schema.IDColumn + " = ? AND (? = '' OR " + schema.NameColumn + " = ? OR " + schema.NameColumn + " IS NULL)"
+	stmtTouch, stmtErr := prepare(touchQ)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	selExpQ := "SELECT " + schema.IDColumn + ", " + schema.NameColumn + ", julianday(" + schema.ExpiresOnColumn + ") FROM " +
+		tableName + schema.expiredCondition() + " AND (? = '' OR " + schema.NameColumn + " = ? OR " + schema.NameColumn + " IS NULL)" +
+		schema.expiredPageCondition() + " ORDER BY julianday(" + schema.ExpiresOnColumn + "), " + schema.IDColumn + " LIMIT ?"
+	stmtSelectExpired, stmtErr := prepare(selExpQ)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+
+	store = &SqliteStore{
+		db:                db,
+		stmtInsert:        stmtInsert,
+		stmtDelete:        stmtDelete,
+		stmtUpdate:        stmtUpdate,
+		stmtSelect:        stmtSelect,
+		stmtExtend:        stmtExtend,
+		stmtExists:        stmtExists,
+		stmtTouch:         stmtTouch,
+		Codecs:            securecookie.CodecsFromPairs(keyPairs...),
+		Options:           cloneOptions(&sessionsOptions),
+		table:             tableName,
+		schema:            schema,
+		stmtInsertWithID:  stmtInsertWithID,
+		stmtSoftDelete:    stmtSoftDelete,
+		stmtSelectExpired: stmtSelectExpired,
+		keyPairs:          keyPairs,
+		busyRetries:       defaultBusyRetries,
+		busyBackoff:       defaultBusyBackoff,
+	}
+	if schema.TextIDs {
+		store.idGenerator = randomID
+	}
+	return store, nil
 }
 
-func (m *SqliteStore) Close() {
-	m.stmtSelect.Close()
-	m.stmtUpdate.Close()
-	m.stmtDelete.Close()
-	m.stmtInsert.Close()
-	m.db.Close()
+// rowScanner is the result of queryRow.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func (m *SqliteStore) Get(r *http.Request, name string) (*sessions.Session, error) {
-	session, err := sessions.GetRegistry(r).Get(m, name)
+// errRow is a rowScanner which fails with err.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
+
+// queryRow prepares the query, runs it and returns the row it selected.
+func (m *SqliteStore) queryRow(ctx context.Context, query string, args ...interface{}) rowScanner {
+	stmt, err := m.db.Prepare(query)
+	if err != nil {
+		return errRow{err: err}
+	}
+	defer stmt.Close()
+	//Row.Scan works even after the closing of the statement, as the row closes the statement only once scanned
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// execStatement prepares the statement and executes it.
+func (m *SqliteStore) execStatement(ctx context.Context, statement string, args ...interface{}) error {
+	stmt, err := m.db.Prepare(statement)
 	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, args...)
+	return err
+}
+
+// ActiveSessionCount returns the number of sessions named sessionName which are not expired yet,
+// an empty sessionName counts the sessions of every name.
+func (m *SqliteStore) ActiveSessionCount(sessionName string) (int, error) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.ActiveSessionCount(sessionName)
+	}
+	if m.closed.Load() {
+		return 0, ErrStoreClosed
+	}
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	stmt, err := m.readStmt(context.Background(), "SELECT COUNT(*) FROM "+m.table+m.schema.activeCondition()+nameCond)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err = stmt.QueryRow(append([]interface{}{timestamp(m.expiryCutoff())}, nameArgs...)...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Counts returns the number of sessions named sessionName which are not expired yet, like ActiveSessionCount, and the
+// number of the expired ones which haven't been deleted by the cleanup yet, counted at the same instant with a single
+// query, e.g. to tell whether the cleanup is falling behind. An empty sessionName counts the sessions of every name.
+func (m *SqliteStore) Counts(sessionName string) (active int, expired int, err error) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.Counts(sessionName)
+	}
+	if m.closed.Load() {
+		return 0, 0, ErrStoreClosed
+	}
+	nameCond, nameArgs := m.schema.nameCondition(sessionName)
+	//the sessions are compared exactly like Schema.activeCondition and Schema.expiredCondition do
+	stmt, err := m.readStmt(context.Background(), "SELECT COALESCE(SUM(CASE WHEN julianday("+m.schema.ExpiresOnColumn+
+		") >= julianday(?) THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN julianday("+m.schema.ExpiresOnColumn+
+		") < julianday(?) THEN 1 ELSE 0 END), 0) FROM "+m.table+" WHERE 1"+m.schema.liveCondition()+nameCond)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := timestamp(m.expiryCutoff())
+	if err = stmt.QueryRow(append([]interface{}{cutoff, cutoff}, nameArgs...)...).Scan(&active, &expired); err != nil {
+		return 0, 0, err
+	}
+	return active, expired, nil
+}
+
+// Ping checks that the database is reachable and that the sessions table exists and can be queried, which may not
+// be the case even if the database is reachable, e.g. after a bad migration. The returned error tells which check failed.
+func (m *SqliteStore) Ping(ctx context.Context) error {
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+
+	if pinger, ok := m.db.(interface{ PingContext(context.Context) error }); ok {
+		if err := pinger.PingContext(ctx); err != nil {
+			return fmt.Errorf("database unreachable: %w", err)
+		}
+	}
+
+	var one int
+	err := m.queryRow(ctx, "SELECT 1 FROM "+m.table+" LIMIT 1").Scan(&one)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("table %s not queryable: %w", m.table, err)
+	}
+	return nil
+}
+
+// DB returns the database the store runs its statements on, e.g. to run a one-off maintenance statement, such as
+// ANALYZE or the creation of an index of the application, on the same connection pool rather than opening another
+// one, whose writes would compete for the locks of the database. It returns nil if the store has been created with a
+// DB which isn't a *sql.DB.
+//
+// Use it carefully: the store manages its own prepared statements and transactions on it, so the database must not
+// be closed, nor the sessions table altered in ways the store doesn't expect, e.g. by dropping its columns. When a
+// read handle has been set (see WithReadDB), the reads of the store run on that one instead.
+func (m *SqliteStore) DB() *sql.DB {
+	db, _ := m.db.(*sql.DB)
+	return db
+}
+
+// Close stops the background cleanups, closes the prepared statements of the store and, unless the store has been
+// created with NewSqliteStoreFromDB, closes the database. Once closed, the store fails every operation with
+// ErrStoreClosed. Calling Close more than once is safe, only the first call closes the store.
+func (m *SqliteStore) Close() error {
+	m.cleanupsMu.Lock()
+	alreadyClosed := m.closed.Swap(true)
+	m.cleanupsMu.Unlock()
+	if alreadyClosed {
+		return nil
+	}
+	m.stopCleanups()
+	m.closeEvents()
+
+	errs := []error{m.closeShards(), m.closeReadStatements(), m.stmts.close()}
+	for _, stmt := range []*sql.Stmt{m.stmtSelectExpired, m.stmtTouch, m.stmtSoftDelete, m.stmtInsertWithID, m.stmtExists, m.stmtExtend, m.stmtSelect, m.stmtUpdate, m.stmtDelete, m.stmtInsert} {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !m.sharedDB {
+		if err := m.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the session named name of the request, caching it in the registry of the request, as New describes:
+// when the error isn't nil, the returned session is a new one.
+func (m *SqliteStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return m.GetContext(r.Context(), r, name)
+}
+
+// GetContext is like Get, but the session is loaded from the database within ctx rather than the context of the
+// request, e.g. to bound the query with a deadline shorter than the request's.
+func (m *SqliteStore) GetContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
+	registry := sessions.GetRegistry(r)
+	if session, err := registry.Get(contextStore{m, ctx}, name); session == nil {
+		return nil, err
+	}
+	//the registry has cached the session, along with the error of New if any, by now, get it again to make it refer
+	//to the store rather than the wrapper
+	session, err := registry.Get(m, name)
+	if session == nil {
 		return nil, err
 	}
 
 	//use store options for sessions
-	session.Options = &sessions.Options{
-		Path:     m.Options.Path,
-		MaxAge:   m.Options.MaxAge,
-		HttpOnly: m.Options.HttpOnly,
-		Secure:   m.Options.Secure,
-		Domain:   m.Options.Domain,
-		SameSite: m.Options.SameSite,
+	session.Options = cloneOptions(m.Options)
+
+	return session, err
+}
+
+// contextStore is the store which the registry of the request is given by GetContext, in order to make the session
+// be loaded within the context passed to GetContext.
+type contextStore struct {
+	*SqliteStore
+	ctx context.Context
+}
+
+func (c contextStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return c.newContext(c.ctx, r, name)
+}
+
+// GetByID loads the session named sessionName with the given ID straight from the database, without looking at any
+// cookie. It returns ErrSessionNotFound if there is no such session and ErrSessionExpired if it is expired.
+func (m *SqliteStore) GetByID(sessionName string, id string) (*sessions.Session, error) {
+	return m.getByID(sessionName, id, false)
+}
+
+// SessionExists reports whether the session named sessionName with the given ID exists and is not expired, without
+// loading it. An empty sessionName matches the sessions of every name.
+func (m *SqliteStore) SessionExists(sessionName string, id string) (bool, error) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.SessionExists(sessionName, id)
+	}
+	if m.closed.Load() {
+		return false, ErrStoreClosed
+	}
+	var one int
+	err := m.existsStmt().QueryRow(timestamp(m.expiryCutoff()), id, sessionName, sessionName).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, m.handleError(context.Background(), err)
+	}
+	return true, nil
+}
+
+// Touch sets the last access time of the session named sessionName with the given ID to now, without changing when
+// it expires. It returns ErrSessionNotFound if there is no such session or it is expired.
+// An empty sessionName matches the sessions of every name.
+func (m *SqliteStore) Touch(sessionName string, id string) error {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.Touch(sessionName, id)
+	}
+	if m.closed.Load() {
+		return ErrStoreClosed
 	}
+	ctx := context.Background()
+	now := m.now()
+	res, err := m.execRetry(ctx, m.stmtTouch, timestamp(now), timestamp(m.expiryCutoff()), id, sessionName, sessionName)
+	if err != nil {
+		return err
+	}
+	touched, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if touched == 0 {
+		return ErrSessionNotFound
+	}
+	m.cacheUpdated(ctx, id, func(row *sessionRow) { row.lastAccess = sql.NullTime{Time: now, Valid: true} })
+	return nil
+}
+
+// GetByIDEvenIfExpired is like GetByID, but it loads the session even if it is expired.
+func (m *SqliteStore) GetByIDEvenIfExpired(sessionName string, id string) (*sessions.Session, error) {
+	return m.getByID(sessionName, id, true)
+}
 
+func (m *SqliteStore) getByID(sessionName string, id string, loadEvenIfExpired bool) (*sessions.Session, error) {
+	if shard := m.shard(sessionName); shard != nil {
+		return shard.getByID(sessionName, id, loadEvenIfExpired)
+	}
+	session := sessions.NewSession(m, sessionName)
+	session.ID = id
+	session.Options = cloneOptions(m.Options)
+	if err := m.load(context.Background(), session, loadEvenIfExpired); err != nil {
+		return nil, err
+	}
 	return session, nil
 }
 
+// New returns the session named name stored in the database whose ID is in the cookie of the request, or a new session
+// if the request has no such cookie, or if its session is not found or expired. When the cookie can't be decoded or
+// the session can't be loaded for any other reason, e.g. because the database is unreachable, a new session is
+// returned along with the error, so that the caller can tell an unknown session apart from a failed request, unless
+// the session is corrupt and SetDropCorruptSessions is enabled.
 func (m *SqliteStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return m.newContext(r.Context(), r, name)
+}
+
+func (m *SqliteStore) newContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
+	if shard := m.shard(name); shard != nil {
+		return shard.newContext(ctx, r, name)
+	}
 	session := sessions.NewSession(m, name)
 	session.IsNew = true
+	//use store options for sessions, as Get does, so that the sessions created by New alone get them as well
+	session.Options = cloneOptions(m.Options)
 	var err error
 	if cook, errCookie := r.Cookie(name); errCookie == nil {
-		err = securecookie.DecodeMulti(name, cook.Value, &session.ID, m.Codecs...)
+		err = securecookie.DecodeMulti(name, cook.Value, &session.ID, m.codecs()...)
 		if err == nil {
-			err = m.load(session, false)
+			err = m.load(ctx, session, false)
 			if err == nil {
 				session.IsNew = false
-			} else {
+				m.slideExpiration(ctx, session)
+			} else if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) {
+				//the cookie refers to a session which is gone, start a new one
 				err = nil
+			} else {
+				err = m.dropCorruptSession(ctx, session, err)
 			}
 		}
 	}
@@ -167,60 +683,173 @@ func (m *SqliteStore) New(r *http.Request, name string) (*sessions.Session, erro
 }
 
 func (m *SqliteStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
-	var err error
-	if session.ID == "" {
-		if err = m.insert(session); err != nil {
+	return m.SaveContext(r.Context(), r, w, session)
+}
+
+// SaveContext is like Save, but the session is written to the database within ctx rather than the context of the
+// request.
+//
+// The session expires Options.MaxAge seconds after being saved, or when it was due to expire if that is later, unless
+// its MaxAge differs from the one of the store: then it expires MaxAge seconds after being saved, so that the expiry
+// of each session can be set through its own MaxAge. A negative MaxAge deletes the session, e.g. to log the user out:
+// its row is deleted right away, its values are cleared and its cookie is replaced by an expired one.
+//
+// The sessions which have already been stored are written with an upsert, so the concurrent saves of the same session,
+// e.g. by two tabs, never fail: the last one wins, and the modification time of the session never goes backwards. A
+// session deleted by someone else in the meantime, e.g. by the cleanup or by Delete in another request, is stored
+// again by the saves which happen after its deletion.
+//
+// The session is written in a transaction, along with the eviction of the oldest sessions of its owner (see
+// SetMaxSessionsPerUser), so either all of them or none is written. The pre-delete and post-delete callbacks called
+// for the evicted sessions run within the transaction, so they must not use the store when the pool of the database
+// has a single connection, which the transaction holds. If the DB of the store has no BeginTx method, the statements
+// are run without a transaction.
+func (m *SqliteStore) SaveContext(ctx context.Context, r *http.Request, w http.ResponseWriter, session *sessions.Session) (err error) {
+	_, err = m.saveContext(ctx, r, w, session)
+	return err
+}
+
+// SaveAndReturn is like Save, but it returns the session as it has just been written, i.e. its ID, which is assigned
+// by the database for the new sessions, its values and its timestamps, without reading it back from the database.
+// If the session is deleted because of its negative MaxAge, no record is returned.
+func (m *SqliteStore) SaveAndReturn(r *http.Request, w http.ResponseWriter, session *sessions.Session) (*SessionRecord, error) {
+	row, err := m.saveContext(r.Context(), r, w, session)
+	if err != nil || row.id == "" {
+		return nil, err
+	}
+	return &SessionRecord{
+		ID:        row.id,
+		Name:      session.Name(),
+		Values:    session.Values,
+		CreatedOn: row.createdOn,
+		ExpiresOn: row.expiresOn,
+	}, nil
+}
+
+// saveContext is SaveContext, returning the row of the session as it has been written.
+func (m *SqliteStore) saveContext(ctx context.Context, r *http.Request, w http.ResponseWriter, session *sessions.Session) (row sessionRow, err error) {
+	if shard := m.shard(session.Name()); shard != nil {
+		return shard.saveContext(ctx, r, w, session)
+	}
+	ctx, span := m.startSpan(ctx, "save", session.Name())
+	defer func() { span.End(err) }()
+	if m.closed.Load() {
+		return sessionRow{}, ErrStoreClosed
+	}
+	if session.Options.MaxAge < 0 {
+		//like with the other gorilla stores, a negative MaxAge deletes the session right away, as Delete does, rather
+		//than storing it already expired until the next cleanup
+		if session.ID != "" {
+			if _, err = m.deleteRow(ctx, DeletionLogout, session.ID, session.Name()); err != nil {
+				return sessionRow{}, err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		for k := range session.Values {
+			delete(session.Values, k)
+		}
+		return sessionRow{}, nil
+	}
+
+	//the session is written along with the sessions its owner has too many of being evicted, and the whole write is
+	//rolled back if any of its statements fails
+	id := session.ID
+	err = m.atomically(ctx, func(ctx context.Context) (err error) {
+		if session.ID == "" {
+			row, err = m.insert(ctx, r, session)
 			return err
 		}
-	} else if err = m.save(session); err != nil {
+		row, err = m.save(ctx, r, session)
 		return err
+	})
+	if err != nil {
+		session.ID = id
+		return sessionRow{}, err
 	}
-	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, m.Codecs...)
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, m.codecs()...)
 	if err != nil {
-		return err
+		return sessionRow{}, err
 	}
 	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
-	return nil
+	return row, nil
 }
 
-func (m *SqliteStore) insert(session *sessions.Session) error {
+// cloneOptions returns a copy of options, e.g. to give each session its own copy of the options of the store, so that
+// the options of a session can be changed without changing the ones of the store or of the other sessions.
+func cloneOptions(options *sessions.Options) *sessions.Options {
+	clone := *options
+	return &clone
+}
+
+// hasOwnMaxAge reports whether the MaxAge of the session has been changed from the one of the store, e.g. to make a
+// "remember me" session last longer, in which case the session expires MaxAge seconds after being saved, rather than
+// when it was due to expire.
+func (m *SqliteStore) hasOwnMaxAge(session *sessions.Session) bool {
+	return session.Options.MaxAge != m.Options.MaxAge
+}
+
+func (m *SqliteStore) insert(ctx context.Context, r *http.Request, session *sessions.Session) (sessionRow, error) {
 	var createdOn time.Time
 	var modifiedOn time.Time
 	var expiresOn time.Time
 	crOn := session.Values["created_on"]
 	if crOn == nil {
-		createdOn = time.Now()
+		createdOn = m.now()
 	} else {
-		createdOn = crOn.(time.Time)
+		createdOn = crOn.(time.Time).In(m.location())
 	}
 	modifiedOn = createdOn
 	exOn := session.Values["expires_on"]
-	if exOn == nil {
-		expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
+	if explicit, ok := expiryFrom(ctx); ok {
+		expiresOn = explicit
+	} else if exOn == nil || m.hasOwnMaxAge(session) {
+		expiresOn = m.now().Add(time.Second * time.Duration(session.Options.MaxAge))
 	} else {
-		expiresOn = exOn.(time.Time)
+		expiresOn = exOn.(time.Time).In(m.location())
 	}
 	delete(session.Values, "created_on")
 	delete(session.Values, "expires_on")
 	delete(session.Values, "modified_on")
+	clientIP, userAgent := m.clientOf(r, session)
 
-	encoded, encErr := securecookie.EncodeMulti(session.Name(), session.Values, m.Codecs...)
+	encoded, encErr := m.encode(session)
 	if encErr != nil {
-		return encErr
+		return sessionRow{}, encErr
 	}
-	res, insErr := m.stmtInsert.Exec(encoded, createdOn, modifiedOn, expiresOn)
+	owner := m.ownerOf(session)
+	if m.idGenerator != nil {
+		id, insErr := m.insertWithGeneratedID(ctx, encoded, createdOn, modifiedOn, expiresOn, session.Name(), owner, clientIP, userAgent)
+		if insErr != nil {
+			return sessionRow{}, insErr
+		}
+		session.ID = id
+		row := sessionRow{id, encoded, createdOn, modifiedOn, expiresOn, clientIP, userAgent, sql.NullTime{}}
+		m.cacheInserted(ctx, row)
+		m.emit(ctx, SessionCreated, session.ID, session.Name())
+		return row, m.evictOldestSessions(ctx, session.Name(), owner)
+	}
+	res, insErr := m.execRetry(ctx, txStmt(ctx, m.stmtInsert), m.dataArg(encoded), timestamp(createdOn), timestamp(modifiedOn), timestamp(expiresOn), session.Name(), owner, clientIP, userAgent)
 	if insErr != nil {
-		return insErr
+		return sessionRow{}, insErr
 	}
 	lastInserted, lInsErr := res.LastInsertId()
 	if lInsErr != nil {
-		return lInsErr
+		return sessionRow{}, lInsErr
 	}
 	session.ID = fmt.Sprintf("%d", lastInserted)
-	return nil
+	row := sessionRow{session.ID, encoded, createdOn, modifiedOn, expiresOn, clientIP, userAgent, sql.NullTime{}}
+	m.cacheInserted(ctx, row)
+	m.emit(ctx, SessionCreated, session.ID, session.Name())
+	return row, m.evictOldestSessions(ctx, session.Name(), owner)
 }
 
 func (m *SqliteStore) Delete(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if shard := m.shard(session.Name()); shard != nil {
+		return shard.Delete(r, w, session)
+	}
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
 	// Set cookie to expire.
 	options := *session.Options
 	options.MaxAge = -1
@@ -230,7 +859,7 @@ func (m *SqliteStore) Delete(r *http.Request, w http.ResponseWriter, session *se
 		delete(session.Values, k)
 	}
 
-	_, delErr := m.stmtDelete.Exec(session.ID)
+	_, delErr := m.deleteRow(r.Context(), DeletionLogout, session.ID, session.Name())
 	if delErr != nil {
 		return delErr
 	}
@@ -238,64 +867,203 @@ func (m *SqliteStore) Delete(r *http.Request, w http.ResponseWriter, session *se
 }
 
 func (m *SqliteStore) DeleteFromDatabaseSessionWithID(sessionID string) (err error) {
-	_, err = m.stmtDelete.Exec(sessionID)
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+	_, err = m.deleteRow(context.Background(), DeletionLogout, sessionID, "")
 	return
 }
 
-func (m *SqliteStore) save(session *sessions.Session) error {
+// DeleteSession deletes the session with the given ID from the database, reporting whether it existed.
+// It is safe to call concurrently with the background cleanup.
+func (m *SqliteStore) DeleteSession(id string) (bool, error) {
+	if m.closed.Load() {
+		return false, ErrStoreClosed
+	}
+	res, err := m.deleteRow(context.Background(), DeletionLogout, id, "")
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteSessionByName is like DeleteSession, but it deletes the session only if it is named name.
+func (m *SqliteStore) DeleteSessionByName(name string, id string) (bool, error) {
+	if shard := m.shard(name); shard != nil {
+		return shard.DeleteSessionByName(name, id)
+	}
+	nameCond, nameArgs := m.schema.nameCondition(name)
+	deleted, err := m.execDelete(context.Background(), DeletionLogout, " WHERE "+m.schema.IDColumn+" = ?"+nameCond, append([]interface{}{id}, nameArgs...)...)
+	if err != nil {
+		return false, err
+	}
+	if deleted > 0 {
+		m.emit(context.Background(), SessionDeleted, id, name)
+	}
+	return deleted > 0, nil
+}
+
+func (m *SqliteStore) save(ctx context.Context, r *http.Request, session *sessions.Session) (sessionRow, error) {
 	if session.IsNew == true {
-		return m.insert(session)
+		return m.insert(ctx, r, session)
 	}
 	var createdOn time.Time
 	var expiresOn time.Time
 	crOn := session.Values["created_on"]
 	if crOn == nil {
-		createdOn = time.Now()
+		createdOn = m.now()
 	} else {
-		createdOn = crOn.(time.Time)
+		createdOn = crOn.(time.Time).In(m.location())
 	}
 
 	exOn := session.Values["expires_on"]
-	if exOn == nil {
-		expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
+	if explicit, ok := expiryFrom(ctx); ok {
+		expiresOn = explicit
+	} else if exOn == nil || m.hasOwnMaxAge(session) {
+		expiresOn = m.now().Add(time.Second * time.Duration(session.Options.MaxAge))
 	} else {
-		expiresOn = exOn.(time.Time)
-		if expiresOn.Sub(time.Now().Add(time.Second*time.Duration(session.Options.MaxAge))) < 0 {
-			expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
+		expiresOn = exOn.(time.Time).In(m.location())
+		if expiresOn.Sub(m.now().Add(time.Second*time.Duration(session.Options.MaxAge))) < 0 {
+			expiresOn = m.now().Add(time.Second * time.Duration(session.Options.MaxAge))
 		}
 	}
 
 	delete(session.Values, "created_on")
 	delete(session.Values, "expires_on")
 	delete(session.Values, "modified_on")
-	encoded, encErr := securecookie.EncodeMulti(session.Name(), session.Values, m.Codecs...)
+	if m.recordClient {
+		//the client the session has been created from is only recorded when it is inserted
+		delete(session.Values, "client_ip")
+		delete(session.Values, "user_agent")
+	}
+	encoded, encErr := m.encode(session)
 	if encErr != nil {
-		return encErr
+		return sessionRow{}, encErr
 	}
-	_, updErr := m.stmtUpdate.Exec(encoded, createdOn, expiresOn, session.ID)
+	modifiedOn := m.now()
+	_, updErr := m.execRetry(ctx, txStmt(ctx, m.stmtUpdate), session.ID, m.dataArg(encoded), timestamp(createdOn), timestamp(modifiedOn), timestamp(expiresOn), session.Name(), m.ownerOf(session))
 	if updErr != nil {
-		return updErr
+		return sessionRow{}, updErr
 	}
-	return nil
+	m.cacheUpdated(ctx, session.ID, func(row *sessionRow) {
+		row.data, row.createdOn, row.modifiedOn, row.expiresOn = encoded, createdOn, modifiedOn, expiresOn
+	})
+	m.emit(ctx, SessionSaved, session.ID, session.Name())
+	//the client is left out, as it isn't updated
+	return sessionRow{id: session.ID, data: encoded, createdOn: createdOn, modifiedOn: modifiedOn, expiresOn: expiresOn}, nil
 }
 
-func (m *SqliteStore) load(session *sessions.Session, loadEvenIfExpired bool) error {
-	row := m.stmtSelect.QueryRow(session.ID)
-	sess := sessionRow{}
-	scanErr := row.Scan(&sess.id, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn)
-	if scanErr != nil {
-		return scanErr
+func (m *SqliteStore) load(ctx context.Context, session *sessions.Session, loadEvenIfExpired bool) (err error) {
+	ctx, span := m.startSpan(ctx, "load", session.Name())
+	defer func() { span.End(err) }()
+	if m.closed.Load() {
+		return ErrStoreClosed
+	}
+
+	cache := m.rowCache(ctx)
+	sess, cached := sessionRow{}, false
+	if cache != nil {
+		sess, cached = cache.get(session.ID, m.now())
+	}
+	if !cached {
+		var gen uint64
+		if cache != nil {
+			gen = cache.generation()
+		}
+		row := m.selectStmt(ctx).QueryRowContext(ctx, session.ID)
+		scanErr := row.Scan(&sess.id, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn, &sess.clientIP, &sess.userAgent, &sess.lastAccess)
+		if scanErr == sql.ErrNoRows {
+			return ErrSessionNotFound
+		}
+		if scanErr != nil {
+			return m.handleError(ctx, scanErr)
+		}
+		if cache != nil {
+			cache.put(sess, m.now(), gen)
+		}
+	}
+	if sess.expiresOn.Sub(m.expiryCutoff()) < 0 && !loadEvenIfExpired {
+		m.log().Debug("Session expired", "session_id", sess.id, "expires_on", sess.expiresOn, "now", m.now())
+		return ErrSessionExpired
 	}
-	if sess.expiresOn.Sub(time.Now()) < 0 && !loadEvenIfExpired {
-		log.Printf("Session expired on %s, but it is %s now.", sess.expiresOn, time.Now())
-		return errors.New("Session expired")
+	if m.isIdle(sess) && !loadEvenIfExpired {
+		m.log().Debug("Session idle", "session_id", sess.id, "last_access", sess.lastAccess.Time, "now", m.now())
+		return ErrSessionExpired
 	}
-	err := securecookie.DecodeMulti(session.Name(), sess.data, &session.Values, m.Codecs...)
+	err = m.decode(sess.data, session)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrSessionCorrupt, err)
 	}
 	session.Values["created_on"] = sess.createdOn
 	session.Values["modified_on"] = sess.modifiedOn
 	session.Values["expires_on"] = sess.expiresOn
+	if m.recordClient {
+		if sess.clientIP.Valid {
+			session.Values["client_ip"] = sess.clientIP.String
+		}
+		if sess.userAgent.Valid {
+			session.Values["user_agent"] = sess.userAgent.String
+		}
+	}
 	return nil
 }
+
+// SetTimeZone sets the time zone in which the created_on, modified_on and expires_on timestamps are stored, by default
+// they are stored in UTC. Expiry checks compare instants, so rows written in different time zones are still
+// compared correctly, but all the writes of a store should use the same time zone to keep the table consistent.
+// The timestamps are stored with their UTC offset, so the sessions of a time zone with daylight saving time expire
+// after their MaxAge even across a change of the offset, e.g. one hour after being saved at 1:30 AM on the day
+// America/New_York springs forward, at 3:30 AM EDT.
+//
+// The rows written by earlier versions of the store, in the local time zone of the server, keep their UTC offset, so
+// they keep expiring at the right instant, and they are stored in UTC, or in the time zone set, the next time their
+// session is saved.
+func (m *SqliteStore) SetTimeZone(loc *time.Location) {
+	m.loc = loc
+}
+
+// SetLocation sets the time zone in which the created_on, modified_on and expires_on timestamps are stored and
+// compared, UTC by default. It is the same as SetTimeZone.
+func (m *SqliteStore) SetLocation(loc *time.Location) {
+	m.SetTimeZone(loc)
+}
+
+// location returns the time zone of the stored timestamps.
+func (m *SqliteStore) location() *time.Location {
+	if m.loc == nil {
+		return time.UTC
+	}
+	return m.loc
+}
+
+// Clock tells the store the current time, which is used to compute and check the expiry of the sessions.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock which tells the actual time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock sets the clock used to compute and check the expiry of the sessions, e.g. to test expiration without
+// sleeping (see sqlitestoretest.FakeClock). By default the store uses the actual time; a nil clock restores it.
+// The interval of the background cleanup is still measured in actual time.
+func (m *SqliteStore) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// now returns the current time in the time zone of the stored timestamps.
+func (m *SqliteStore) now() time.Time {
+	clock := m.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return clock.Now().In(m.location())
+}
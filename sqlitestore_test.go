@@ -0,0 +1,50 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// failingPrepareDB is a DB whose Prepare fails for the queries containing failOn, recording the statements it
+// prepared.
+type failingPrepareDB struct {
+	*sql.DB
+	failOn   string
+	prepared []*sql.Stmt
+}
+
+func (db *failingPrepareDB) Prepare(query string) (*sql.Stmt, error) {
+	if strings.Contains(query, db.failOn) {
+		return nil, errors.New("prepare failed")
+	}
+	stmt, err := db.DB.Prepare(query)
+	if err == nil {
+		db.prepared = append(db.prepared, stmt)
+	}
+	return stmt, err
+}
+
+func TestNewClosesTheStatementsWhenPrepareFails(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite3", "file:prepare_fails?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db := &failingPrepareDB{DB: sqlDB, failOn: "julianday(`expires_on`) FROM"}
+
+	if _, err = NewSqliteStoreFromConnection(db, "sessions", sessions.Options{}); err == nil {
+		t.Fatal("creating the store succeeded although a statement couldn't be prepared")
+	}
+	if len(db.prepared) == 0 {
+		t.Fatal("no statement has been prepared before the failing one")
+	}
+	for _, stmt := range db.prepared {
+		if _, err = stmt.Exec(); err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Errorf("a statement prepared before the failing one is still open, running it returned %v", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package sqlitestoremsgpack provides a sqlitestore.Serializer which encodes the session values with MessagePack.
+//
+// It lives in its own package so that the MessagePack library is only a dependency of the programs which import it.
+package sqlitestoremsgpack
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Serializer encodes the session values as a MessagePack map, so that the stored data can be read by the other
+// services using MessagePack. Like with sqlitestore.JSONSerializer, the keys of the values must be strings, and the
+// values must be encodable with github.com/vmihailenco/msgpack: serializing anything else fails instead of storing
+// data which can't be decoded back. The values are decoded back as strings, int64s, uint64s, float64s, bools, nils,
+// []byte, time.Time, []interface{} and map[string]interface{}.
+//
+// Use it with store.SetSerializer(sqlitestoremsgpack.Serializer{}).
+type Serializer struct{}
+
+func (Serializer) Serialize(values map[interface{}]interface{}) ([]byte, error) {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unable to MessagePack encode the session values: non-string key %#v", k)
+		}
+		m[ks] = v
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	//sort the keys, so that the same values are always encoded into the same data
+	enc.SetSortMapKeys(true)
+	if err := enc.Encode(m); err != nil {
+		return nil, fmt.Errorf("unable to MessagePack encode the session values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Format returns the format of the data serialized with MessagePack, see sqlitestore.FormatSerializer.
+func (Serializer) Format() byte { return 'm' }
+
+func (Serializer) Deserialize(data []byte, session *sessions.Session) error {
+	m := make(map[string]interface{})
+	if err := msgpack.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		session.Values[k] = widen(v)
+	}
+	return nil
+}
+
+// widen returns the decoded value with its integers widened to int64s and uint64s, and its float32s to float64s,
+// whatever the size they have been encoded with, going through the nested slices and maps. Unlike the loose decoding
+// of msgpack, it keeps the []byte values as they are rather than decoding them as strings.
+func widen(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint:
+		return uint64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = widen(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = widen(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,127 @@
+package sqlitestoremsgpack
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maxbarbieri/sqlitestore"
+)
+
+// newStore returns an in-memory store encoding the session values with serializer, nil for the default one.
+func newStore(t *testing.T, serializer sqlitestore.Serializer) *sqlitestore.SqliteStore {
+	t.Helper()
+	opts := []sqlitestore.Option{sqlitestore.WithKeyPairs([]byte("test hash key"))}
+	if serializer != nil {
+		opts = append(opts, sqlitestore.WithSerializer(serializer))
+	}
+	store, err := sqlitestore.NewInMemory(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestValuesRoundTripThroughTheStore(t *testing.T) {
+	store := newStore(t, Serializer{})
+	session, err := store.New(httptest.NewRequest("GET", "/", nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggedIn := time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC)
+	session.Values["user"] = "alice"
+	session.Values["visits"] = int8(3)
+	session.Values["quota"] = uint32(1 << 20)
+	session.Values["ratio"] = 0.5
+	session.Values["admin"] = true
+	session.Values["avatar"] = []byte{0xff, 0x00}
+	session.Values["logged_in"] = loggedIn
+	session.Values["nothing"] = nil
+	session.Values["cart"] = map[string]interface{}{
+		"items": []interface{}{"book", int16(2)},
+		"coupon": map[string]interface{}{
+			"code":    "WELCOME",
+			"percent": uint8(10),
+		},
+	}
+	if err = store.Save(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.GetByID("session", session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the integers are decoded back as int64s and uint64s, whatever their size
+	want := map[interface{}]interface{}{
+		"user":    "alice",
+		"visits":  int64(3),
+		"quota":   uint64(1 << 20),
+		"ratio":   0.5,
+		"admin":   true,
+		"avatar":  []byte{0xff, 0x00},
+		"nothing": nil,
+		"cart": map[string]interface{}{
+			"items": []interface{}{"book", int64(2)},
+			"coupon": map[string]interface{}{
+				"code":    "WELCOME",
+				"percent": uint64(10),
+			},
+		},
+	}
+	for key, value := range want {
+		if got := loaded.Values[key]; !reflect.DeepEqual(got, value) {
+			t.Errorf("the value of %q is %#v, want %#v", key, got, value)
+		}
+	}
+	if got, ok := loaded.Values["logged_in"].(time.Time); !ok || !got.Equal(loggedIn) {
+		t.Errorf("the value of \"logged_in\" is %#v, want %v", loaded.Values["logged_in"], loggedIn)
+	}
+}
+
+func TestSerializeIsDeterministic(t *testing.T) {
+	values := map[interface{}]interface{}{"a": 1, "b": map[string]interface{}{"c": 2, "d": 3}, "e": "f"}
+	first, err := Serializer{}.Serialize(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if data, err := (Serializer{}).Serialize(values); err != nil || !bytes.Equal(data, first) {
+			t.Fatalf("serializing the same values returned %x and error %v, want %x", data, err, first)
+		}
+	}
+}
+
+func TestUnserializableValues(t *testing.T) {
+	type key int
+	for _, tc := range []struct {
+		name   string
+		values map[interface{}]interface{}
+	}{
+		{"unsupported value", map[interface{}]interface{}{"events": make(chan int)}},
+		//the MessagePack serializer only encodes the string keys, gob only the registered types
+		{"typed key", map[interface{}]interface{}{key(1): "value"}},
+	} {
+		for _, serializer := range []sqlitestore.Serializer{nil, Serializer{}} {
+			store := newStore(t, serializer)
+			session, err := store.New(httptest.NewRequest("GET", "/", nil), "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.values {
+				session.Values[k] = v
+			}
+			err = store.Save(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), session)
+			if !errors.Is(err, sqlitestore.ErrSerialization) {
+				t.Errorf("%s: saving with the serializer %T returned %v, want an error wrapping ErrSerialization", tc.name, serializer, err)
+			}
+			if count, err := store.ActiveSessionCount(""); err != nil || count != 0 {
+				t.Errorf("%s: %d sessions are stored with error %v, want none", tc.name, count, err)
+			}
+		}
+	}
+}
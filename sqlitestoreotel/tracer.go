@@ -0,0 +1,57 @@
+// Package sqlitestoreotel traces the operations of a sqlitestore.SqliteStore with OpenTelemetry.
+//
+// It lives in its own package so that OpenTelemetry is only a dependency of the programs which import it.
+package sqlitestoreotel
+
+import (
+	"context"
+
+	"github.com/maxbarbieri/sqlitestore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/maxbarbieri/sqlitestore"
+
+// SetTracerProvider makes the store wrap its loads, saves and cleanups in spans created by a tracer of tp.
+// Each span carries the session name, the cleanup spans carry the number of sessions examined and deleted as well.
+func SetTracerProvider(store *sqlitestore.SqliteStore, tp trace.TracerProvider) {
+	store.SetTracer(NewTracer(tp))
+}
+
+// NewTracer returns a sqlitestore.Tracer which creates its spans with a tracer of tp.
+func NewTracer(tp trace.TracerProvider) sqlitestore.Tracer {
+	return tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, operation string, sessionName string) (context.Context, sqlitestore.Span) {
+	ctx, s := t.tracer.Start(ctx, "sqlitestore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("sqlitestore.session_name", sessionName),
+		),
+	)
+	return ctx, span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetInt(key string, value int) {
+	s.span.SetAttributes(attribute.Int(key, value))
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
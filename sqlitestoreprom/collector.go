@@ -0,0 +1,105 @@
+// Package sqlitestoreprom exposes the internals of a sqlitestore.SqliteStore as Prometheus metrics.
+//
+// It lives in its own package so that the Prometheus client is only a dependency of the programs which import it.
+package sqlitestoreprom
+
+import (
+	"github.com/maxbarbieri/sqlitestore"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "sqlitestore"
+
+// Collector is a prometheus.Collector which exposes the following metrics of a store:
+//
+//   - sqlitestore_active_sessions: gauge of the sessions which are not expired yet, queried on every scrape
+//   - sqlitestore_cleanup_deleted_sessions_total: counter of the expired sessions deleted by the cleanups
+//   - sqlitestore_cleanup_duration_seconds: histogram of the duration of the cleanups
+//   - sqlitestore_cleanup_errors_total: counter of the failed cleanups
+//
+// Every metric has a session_name label. The active sessions are reported for each of the session names the
+// collector has been created with, while the cleanup metrics get a series for each session name a cleanup has been
+// run for, the cleanups of every session name (like the ones started by StartCleanupAll) being reported with an empty
+// session_name. The cardinality is therefore bounded by the number of distinct session names used by the
+// application, which should be kept small: never use a per-user value as a session name.
+type Collector struct {
+	store        *sqlitestore.SqliteStore
+	sessionNames []string
+
+	activeSessions  *prometheus.Desc
+	deletedSessions *prometheus.CounterVec
+	cleanupDuration *prometheus.HistogramVec
+	cleanupErrors   *prometheus.CounterVec
+}
+
+// NewCollector creates a collector for the store, which reports the active sessions of each of the given session
+// names, or of all the sessions (with an empty session_name) if none is given. The collector starts observing the
+// cleanups of the store straight away, it still has to be registered, e.g. with prometheus.MustRegister.
+func NewCollector(store *sqlitestore.SqliteStore, sessionNames ...string) *Collector {
+	if len(sessionNames) == 0 {
+		sessionNames = []string{""}
+	}
+
+	c := &Collector{
+		store:        store,
+		sessionNames: sessionNames,
+		activeSessions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_sessions"),
+			"Number of sessions which are not expired yet.",
+			[]string{"session_name"}, nil,
+		),
+		deletedSessions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "deleted_sessions_total",
+			Help:      "Number of expired sessions deleted by the cleanups.",
+		}, []string{"session_name"}),
+		cleanupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "duration_seconds",
+			Help:      "Duration of the cleanups of the expired sessions.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"session_name"}),
+		cleanupErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "errors_total",
+			Help:      "Number of cleanups of the expired sessions which failed.",
+		}, []string{"session_name"}),
+	}
+	store.AddCleanupObserver(c.observeCleanup)
+	return c
+}
+
+// observeCleanup updates the cleanup metrics with the outcome of a cleanup.
+func (c *Collector) observeCleanup(result sqlitestore.CleanupResult) {
+	c.deletedSessions.WithLabelValues(result.SessionName).Add(float64(result.Deleted))
+	c.cleanupDuration.WithLabelValues(result.SessionName).Observe(result.Duration.Seconds())
+	if result.Err != nil {
+		c.cleanupErrors.WithLabelValues(result.SessionName).Inc()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activeSessions
+	c.deletedSessions.Describe(ch)
+	c.cleanupDuration.Describe(ch)
+	c.cleanupErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, sessionName := range c.sessionNames {
+		count, err := c.store.ActiveSessionCount(sessionName)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.activeSessions, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.activeSessions, prometheus.GaugeValue, float64(count), sessionName)
+	}
+	c.deletedSessions.Collect(ch)
+	c.cleanupDuration.Collect(ch)
+	c.cleanupErrors.Collect(ch)
+}
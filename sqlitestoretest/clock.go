@@ -0,0 +1,48 @@
+// Package sqlitestoretest provides helpers to test code which uses a sqlitestore.SqliteStore.
+package sqlitestoretest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a sqlitestore.Clock which only moves when told to, so that the expiration of the sessions can be
+// tested without sleeping:
+//
+//	clock := sqlitestoretest.NewFakeClock(time.Now())
+//	store.SetClock(clock)
+//	// save a session with a MaxAge of 60 seconds
+//	clock.Advance(61 * time.Second)
+//	// the session is now expired, and it is deleted by the next cleanup
+//
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is set to.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,78 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// stmtCache holds the statements prepared on a DB, by query, so that the queries which aren't prepared when the store
+// is created, like the ones of the cleanups, are only prepared once.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// get returns the statement of the query prepared on db, preparing it if it hasn't been prepared yet. The statement
+// must not be closed, it remains in the cache until the cache is closed.
+func (c *stmtCache) get(db DB, query string) (*sql.Stmt, error) {
+	if stmt := c.lookup(query); stmt != nil {
+		return stmt, nil
+	}
+
+	//prepare the statement without holding the lock, as preparing may wait for a connection, which may be held by a
+	//transaction waiting for the lock
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.stmts[query]; ok {
+		//prepared concurrently by someone else
+		stmt.Close()
+		return cached, nil
+	}
+	if c.stmts == nil {
+		c.stmts = make(map[string]*sql.Stmt)
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// lookup returns the statement of the query, nil if it hasn't been prepared yet.
+func (c *stmtCache) lookup(query string) *sql.Stmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stmts[query]
+}
+
+// close closes all the statements of the cache and empties it.
+func (c *stmtCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.stmts = nil
+	return errors.Join(errs...)
+}
+
+// stmt returns the statement of the query, prepared on the DB of the store and cached, or bound to the transaction of
+// ctx if any. The statement must not be closed: the cached ones are closed by Close, the ones bound to the transaction
+// when it ends. Within a transaction, the queries which haven't been cached yet are prepared on the transaction
+// rather than on the DB, as the transaction may hold the only connection of the pool.
+func (m *SqliteStore) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if state := txFrom(ctx); state != nil {
+		if stmt := m.stmts.lookup(query); stmt != nil {
+			return state.tx.StmtContext(ctx, stmt), nil
+		}
+		stmt, err := state.tx.PrepareContext(ctx, query)
+		return stmt, classifyError(err)
+	}
+	return m.stmts.get(m.db, query)
+}
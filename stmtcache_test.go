@@ -0,0 +1,79 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestStatementCacheStaysBounded(t *testing.T) {
+	store, clock := newTestStore(t)
+	db := store.db.(*sql.DB)
+	//the cleanups select the idle sessions with a cached query, and delete the expired ones in chunks
+	store.SetIdleTimeout(time.Hour)
+	store.SetCleanupDeleteChunkSize(3)
+	store.SetExpiredSessionPostDeleteCallback(func(*sessions.Session) {})
+
+	ctx := context.Background()
+	cachedSize, openConnections := 0, 0
+	for i := 0; i < 2000; i++ {
+		if i%2 == 0 {
+			saveSession(t, store, "session", 60, nil)
+		}
+		stmt, err := store.stmt(ctx, "SELECT COUNT(*) FROM sessions WHERE ? >= 0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err = stmt.QueryRowContext(ctx, i).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		//within a transaction, the cached statements are bound to it and the others are prepared on it
+		err = store.atomically(ctx, func(ctx context.Context) error {
+			for _, query := range []string{"SELECT COUNT(*) FROM sessions WHERE ? >= 0", "SELECT COUNT(*) FROM sessions WHERE id > ?"} {
+				stmt, err := store.stmt(ctx, query)
+				if err != nil {
+					return err
+				}
+				if err = stmt.QueryRowContext(ctx, i).Scan(&count); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 9 {
+			clock.Advance(time.Minute)
+			if _, err = store.CleanupNow(""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		store.stmts.mu.Lock()
+		size := len(store.stmts.stmts)
+		store.stmts.mu.Unlock()
+		open := db.Stats().OpenConnections
+		if i == 100 {
+			//every query has been run, and cached if it ever is, by now
+			cachedSize, openConnections = size, open
+		} else if i > 100 && (size > cachedSize || open > openConnections) {
+			t.Fatalf("after %d iterations, %d statements are cached and %d connections are open, want at most %d and %d",
+				i+1, size, open, cachedSize, openConnections)
+		}
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.stmts.stmts) != 0 {
+		t.Errorf("%d statements are left in the cache of the closed store", len(store.stmts.stmts))
+	}
+	if open := db.Stats().OpenConnections; open != 0 {
+		t.Errorf("%d connections are left open by the closed store", open)
+	}
+}
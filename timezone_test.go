@@ -0,0 +1,89 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionExpiresAfterItsMaxAge(t *testing.T) {
+	for _, loc := range []*time.Location{nil, time.FixedZone("UTC+5", 5*3600), time.FixedZone("UTC-8", -8*3600)} {
+		store, clock := newTestStore(t)
+		if loc != nil {
+			store.SetTimeZone(loc)
+		}
+		saved := saveSession(t, store, "session", 1, nil)
+
+		clock.Advance(500 * time.Millisecond)
+		if _, err := store.GetByID("session", saved.ID); err != nil {
+			t.Errorf("in %v, the session expired before its MaxAge: %v", loc, err)
+		}
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 0 {
+			t.Errorf("in %v, the cleanup deleted %d sessions with error %v before their expiry", loc, deleted, err)
+		}
+
+		clock.Advance(time.Second)
+		if _, err := store.GetByID("session", saved.ID); !errors.Is(err, ErrSessionExpired) {
+			t.Errorf("in %v, loading the session after its MaxAge returned %v, want ErrSessionExpired", loc, err)
+		}
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+			t.Errorf("in %v, the cleanup deleted %d sessions with error %v after their expiry, want 1", loc, deleted, err)
+		}
+	}
+}
+
+func TestTimestampsAreStoredInTheTimeZone(t *testing.T) {
+	store, _ := newTestStore(t)
+	store.SetTimeZone(time.FixedZone("UTC+5", 5*3600))
+	saved := saveSession(t, store, "session", 3600, nil)
+
+	var expiresOn time.Time
+	if err := store.queryRow(context.Background(), "SELECT expires_on FROM sessions WHERE id = ?", saved.ID).Scan(&expiresOn); err != nil {
+		t.Fatal(err)
+	}
+	if _, offset := expiresOn.Zone(); !expiresOn.Equal(testEpoch.Add(time.Hour)) || offset != 5*3600 {
+		t.Errorf("the expiry is stored as %v, want it an hour after %v in UTC+5", expiresOn, testEpoch)
+	}
+}
+
+func TestSessionExpiresAfterItsMaxAgeAcrossDaylightSavingTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("the time zone database isn't available: %v", err)
+	}
+	for _, tc := range []struct {
+		name          string
+		savedAt       time.Time
+		maxAge        int
+		before, after time.Time
+	}{
+		//the clocks spring forward from 2:00 EST to 3:00 EDT, the session expires at 3:30 EDT
+		{"spring forward", time.Date(2026, 3, 8, 1, 30, 0, 0, newYork), 3600,
+			time.Date(2026, 3, 8, 3, 29, 0, 0, newYork), time.Date(2026, 3, 8, 3, 31, 0, 0, newYork)},
+		//the clocks fall back from 2:00 EDT to 1:00 EST, the session expires at the second 1:30, in EST
+		{"fall back", time.Date(2026, 11, 1, 0, 30, 0, 0, newYork), 7200,
+			time.Date(2026, 11, 1, 1, 30, 0, 0, time.FixedZone("EDT", -4*3600)), time.Date(2026, 11, 1, 1, 31, 0, 0, time.FixedZone("EST", -5*3600))},
+	} {
+		store, clock := newTestStore(t)
+		store.SetLocation(newYork)
+		clock.Set(tc.savedAt)
+		saved := saveSession(t, store, "session", tc.maxAge, nil)
+
+		clock.Set(tc.before)
+		if _, err := store.GetByID("session", saved.ID); err != nil {
+			t.Errorf("%s: the session expired before its MaxAge: %v", tc.name, err)
+		}
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 0 {
+			t.Errorf("%s: the cleanup deleted %d sessions with error %v before their expiry", tc.name, deleted, err)
+		}
+
+		clock.Set(tc.after)
+		if _, err := store.GetByID("session", saved.ID); !errors.Is(err, ErrSessionExpired) {
+			t.Errorf("%s: loading the session after its MaxAge returned %v, want ErrSessionExpired", tc.name, err)
+		}
+		if deleted, err := store.CleanupNow(""); err != nil || deleted != 1 {
+			t.Errorf("%s: the cleanup deleted %d sessions with error %v after their expiry, want 1", tc.name, deleted, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package sqlitestore
+
+import "context"
+
+// Tracer traces the operations of the store, e.g. by wrapping them in OpenTelemetry spans:
+// see the sqlitestoreotel package for an implementation based on an OpenTelemetry TracerProvider.
+type Tracer interface {
+	// Start starts a span for the operation (load, save or cleanup) on the sessions named sessionName and returns
+	// the context carrying it.
+	Start(ctx context.Context, operation string, sessionName string) (context.Context, Span)
+}
+
+// Span is a traced operation of the store.
+type Span interface {
+	// SetInt sets an integer attribute of the span, such as the number of sessions deleted by a cleanup.
+	SetInt(key string, value int)
+	// End ends the span, err is the error the operation failed with, if any.
+	End(err error)
+}
+
+// SetTracer sets the tracer used to trace the operations of the store, it should be called before the store is used.
+// By default the operations are not traced.
+func (m *SqliteStore) SetTracer(tracer Tracer) {
+	m.tracer = tracer
+}
+
+// noopSpan is the span of the operations when no tracer has been set.
+type noopSpan struct{}
+
+func (noopSpan) SetInt(string, int) {}
+func (noopSpan) End(error)          {}
+
+// startSpan starts a span for the operation with the tracer, if one has been set.
+func (m *SqliteStore) startSpan(ctx context.Context, operation string, sessionName string) (context.Context, Span) {
+	if m.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return m.tracer.Start(ctx, operation, sessionName)
+}
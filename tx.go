@@ -0,0 +1,104 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrTransactionsUnsupported is returned by the operations which must be atomic when the DB of the store can't begin
+// transactions, i.e. it has no BeginTx method like the one of *sql.DB.
+var ErrTransactionsUnsupported = errors.New("Transactions unsupported by the database")
+
+// txBeginner is the part of *sql.DB which begins the transactions. It isn't part of DB, which would break the DBs
+// implemented without it.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise, even if fn panics.
+func (m *SqliteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	beginner, ok := m.db.(txBeginner)
+	if !ok {
+		return ErrTransactionsUnsupported
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		//a panic of fn must not leave the transaction open, holding its connection and the write lock
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			m.log().Error("Error rolling back transaction", "error", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// txState is the transaction the operations are run in, stored in their context by atomically, along with the events
+// to emit and the functions to run once it has been committed.
+type txState struct {
+	tx        *sql.Tx
+	events    []SessionEvent
+	committed []func()
+}
+
+// txContextKey is the key of the txState in the context.
+type txContextKey struct{}
+
+// atomically runs fn in a transaction, passing it a context which makes the operations of the store run within the
+// transaction, see txStmt and prepare. The events emitted by fn are only sent once the transaction has been committed.
+// If the DB of the store can't begin transactions, fn is run without a transaction, while if ctx already runs the
+// operations in a transaction, fn is run in it.
+func (m *SqliteStore) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := m.db.(txBeginner); !ok || txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+	state := &txState{}
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txContextKey{}, state))
+	})
+	if err != nil {
+		return err
+	}
+	for _, fn := range state.committed {
+		fn()
+	}
+	for _, event := range state.events {
+		m.emit(ctx, event.Type, event.SessionID, event.SessionName)
+	}
+	return nil
+}
+
+// afterCommit runs fn once the transaction of ctx has been committed, or straight away if ctx runs the operations
+// without a transaction. fn is never run if the transaction is rolled back.
+func (m *SqliteStore) afterCommit(ctx context.Context, fn func()) {
+	if state := txFrom(ctx); state != nil {
+		state.committed = append(state.committed, fn)
+		return
+	}
+	fn()
+}
+
+// txFrom returns the transaction the operations run with ctx must be run in, nil if there is none.
+func txFrom(ctx context.Context) *txState {
+	state, _ := ctx.Value(txContextKey{}).(*txState)
+	return state
+}
+
+// txStmt returns the prepared statement of the store bound to the transaction of ctx, if any.
+func txStmt(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if state := txFrom(ctx); state != nil {
+		return state.tx.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
@@ -0,0 +1,61 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestAtomicallyRollsBackOnError(t *testing.T) {
+	store, _ := newTestStore(t)
+	errFailed := errors.New("failed")
+	err := store.atomically(context.Background(), func(ctx context.Context) error {
+		if _, err := store.execRetry(ctx, txStmt(ctx, store.stmtInsertWithID), "1", []byte("data"), timestamp(store.now()),
+			timestamp(store.now()), timestamp(store.now()), "session", nil, nil, nil); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("atomically returned %v, want the error of fn", err)
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions have been stored by the rolled back transaction, want none", count)
+	}
+}
+
+func TestInTxRollsBackOnPanic(t *testing.T) {
+	store, _ := newTestStore(t)
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want the panic of fn", p)
+			}
+		}()
+		store.inTx(context.Background(), func(tx *sql.Tx) error {
+			if _, err := tx.Stmt(store.stmtInsertWithID).Exec("1", []byte("data"), timestamp(store.now()),
+				timestamp(store.now()), timestamp(store.now()), "session", nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			panic("boom")
+		})
+	}()
+
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions have been stored by the panicking transaction, want none", count)
+	}
+	//the database isn't locked by a transaction left open
+	saveSession(t, store, "session", 3600, nil)
+}
+
+func TestBulkInsertIsAtomic(t *testing.T) {
+	store, _ := newTestStore(t)
+	err := store.BulkInsert([]SessionRecord{{ID: "1", Name: "session"}, {ID: "not an integer", Name: "session"}}, false)
+	if err == nil {
+		t.Fatal("inserting a text ID in the INTEGER PRIMARY KEY succeeded")
+	}
+	if count := countRows(t, store, "sessions"); count != 0 {
+		t.Errorf("%d sessions have been inserted by the failed bulk insert, want none", count)
+	}
+}
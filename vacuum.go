@@ -0,0 +1,109 @@
+package sqlitestore
+
+import (
+	"context"
+	"strings"
+)
+
+// VacuumMode tells how the database is vacuumed after a cleanup which deleted many sessions.
+type VacuumMode int
+
+const (
+	// VacuumOff never vacuums the database.
+	VacuumOff VacuumMode = iota
+	// VacuumIncremental runs PRAGMA incremental_vacuum, which returns the free pages to the file system without
+	// locking the database for long. It only has an effect if the database uses auto_vacuum = INCREMENTAL, which
+	// must be set before the first table is created (or be followed by a VACUUM).
+	VacuumIncremental
+	// VacuumFull runs VACUUM, which rebuilds the whole database and locks it while doing so. It is skipped when the
+	// database is in WAL mode, where readers would be blocked and the WAL file may grow as large as the database.
+	VacuumFull
+	// VacuumFullEvenInWAL runs VACUUM like VacuumFull does, but in WAL mode as well.
+	VacuumFullEvenInWAL
+)
+
+// SetVacuumAfterCleanup sets how the database is vacuumed after a cleanup which deleted more than threshold sessions,
+// to give back to the file system the space they took. By default the database is never vacuumed.
+func (m *SqliteStore) SetVacuumAfterCleanup(mode VacuumMode, threshold int) {
+	m.vacuumMode = mode
+	m.vacuumThreshold = threshold
+}
+
+// vacuumAfterCleanup vacuums the database if the cleanup deleted enough sessions. Failures are only logged, as the
+// cleanup itself succeeded.
+func (m *SqliteStore) vacuumAfterCleanup(ctx context.Context, deleted int) {
+	if m.vacuumMode == VacuumOff || deleted <= m.vacuumThreshold {
+		return
+	}
+
+	var statement string
+	switch m.vacuumMode {
+	case VacuumIncremental:
+		statement = "PRAGMA incremental_vacuum"
+	case VacuumFull, VacuumFullEvenInWAL:
+		if m.vacuumMode == VacuumFull {
+			journalMode, err := m.pragmaString(ctx, "journal_mode")
+			if err != nil {
+				m.log().Error("Error reading the journal mode", "error", err)
+				return
+			}
+			if strings.EqualFold(journalMode, "wal") {
+				m.log().Debug("Skipping VACUUM in WAL mode")
+				return
+			}
+		}
+		statement = "VACUUM"
+	default:
+		return
+	}
+
+	run := m.execStatement
+	if m.vacuumMode == VacuumIncremental {
+		run = m.drainStatement
+	}
+	sizeBefore, sizeErr := m.databaseSize(ctx)
+	if err := run(ctx, statement); err != nil {
+		m.log().Error("Error vacuuming the database", "statement", statement, "error", err)
+		return
+	}
+	if sizeAfter, err := m.databaseSize(ctx); err == nil && sizeErr == nil {
+		m.log().Info("Vacuumed the database", "statement", statement, "reclaimed_bytes", sizeBefore-sizeAfter)
+	}
+}
+
+// drainStatement prepares the statement and steps through all of its rows, as the pragmas like incremental_vacuum only
+// do their work a step at a time, e.g. freeing a single page each, and stop as soon as the statement is reset.
+func (m *SqliteStore) drainStatement(ctx context.Context, statement string, args ...interface{}) error {
+	stmt, err := m.db.Prepare(statement)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// databaseSize returns the size of the database, in bytes.
+func (m *SqliteStore) databaseSize(ctx context.Context) (int64, error) {
+	var pageCount, pageSize int64
+	if err := m.queryRow(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := m.queryRow(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pageCount * pageSize, nil
+}
+
+// pragmaString returns the value of the pragma.
+func (m *SqliteStore) pragmaString(ctx context.Context, name string) (string, error) {
+	var value string
+	err := m.queryRow(ctx, "PRAGMA "+name).Scan(&value)
+	return value, err
+}
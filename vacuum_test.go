@@ -0,0 +1,29 @@
+package sqlitestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncrementalVacuumFreesEveryPage(t *testing.T) {
+	store, clock := newTestStore(t, WithPragmas(Pragma{Name: "auto_vacuum", Value: "INCREMENTAL"}))
+	store.SetVacuumAfterCleanup(VacuumIncremental, 0)
+	value := strings.Repeat("x", 2000)
+	for i := 0; i < 300; i++ {
+		saveSession(t, store, "session", 60, map[interface{}]interface{}{"value": value})
+	}
+
+	clock.Advance(2 * time.Minute)
+	if deleted, err := store.CleanupNow(""); err != nil || deleted != 300 {
+		t.Fatalf("cleanup deleted %d sessions with error %v, want 300 and no error", deleted, err)
+	}
+	var freePages int
+	if err := store.queryRow(context.Background(), "PRAGMA freelist_count").Scan(&freePages); err != nil {
+		t.Fatal(err)
+	}
+	if freePages != 0 {
+		t.Errorf("%d pages are still free after the vacuum, want none", freePages)
+	}
+}